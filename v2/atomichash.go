@@ -0,0 +1,433 @@
+package doublejump
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// AtomicHash is a thread-safe wrapper of Hash with a lock-free read path.
+// Every modification builds a new copy of the inner holders and publishes
+// it atomically, so lookups never wait for writers. It is designed for the
+// workloads in which lookups vastly outnumber membership changes.
+type AtomicHash[T comparable] struct {
+	mu sync.Mutex
+	v  atomic.Value
+}
+
+// NewAtomicHash creates a new copy-on-write doublejump hash instance.
+func NewAtomicHash[T comparable]() *AtomicHash[T] {
+	return NewAtomicHashWithOptions[T]()
+}
+
+// NewAtomicHashWithKeyHasher creates a new copy-on-write doublejump hash
+// instance which uses hasher to turn string and byte-slice keys into uint64
+// keys.
+func NewAtomicHashWithKeyHasher[T comparable](hasher KeyHasher) *AtomicHash[T] {
+	return NewAtomicHashWithOptions[T](WithKeyHasher(hasher))
+}
+
+// NewAtomicHashWithOptions creates a new copy-on-write doublejump hash
+// instance with options, e.g. WithKeyHasher and WithHashTags.
+func NewAtomicHashWithOptions[T comparable](opts ...Option) *AtomicHash[T] {
+	a := &AtomicHash[T]{}
+	h := NewHashWithOptions[T](opts...)
+	h.lockRand()
+	a.v.Store(h)
+	return a
+}
+
+// load returns the current snapshot.
+func (a *AtomicHash[T]) load() *Hash[T] {
+	return a.v.Load().(*Hash[T])
+}
+
+// Update applies fn to a private copy of the current snapshot and publishes
+// the result. Use it to batch several modifications into one copy. fn must
+// not retain h after it returns.
+func (a *AtomicHash[T]) Update(fn func(h *Hash[T])) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	h := a.load().clone()
+	fn(h)
+	h.lockRand()
+	a.v.Store(h)
+}
+
+// Add adds an object to the hash.
+func (a *AtomicHash[T]) Add(obj T) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cur := a.load()
+	if _, ok := cur.compact.m[obj]; ok {
+		return
+	}
+	h := cur.clone()
+	h.Add(obj)
+	a.v.Store(h)
+}
+
+// AddWeighted adds an object to the hash with the given weight.
+func (a *AtomicHash[T]) AddWeighted(obj T, weight int) {
+	if weight < 1 {
+		panic("doublejump: weight must be positive")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cur := a.load()
+	if _, ok := cur.compact.m[obj]; ok {
+		return
+	}
+	h := cur.clone()
+	h.AddWeighted(obj, weight)
+	a.v.Store(h)
+}
+
+// SetWeight changes the weight of an existing object.
+func (a *AtomicHash[T]) SetWeight(obj T, weight int) {
+	if weight < 1 {
+		panic("doublejump: weight must be positive")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cur := a.load()
+	if w := cur.Weight(obj); w == 0 || w == weight {
+		return
+	}
+	h := cur.clone()
+	h.SetWeight(obj, weight)
+	a.v.Store(h)
+}
+
+// Weight returns the weight of an object, or 0 if the object is not in the hash.
+func (a *AtomicHash[T]) Weight(obj T) int {
+	return a.load().Weight(obj)
+}
+
+// Remove removes an object from the hash.
+func (a *AtomicHash[T]) Remove(obj T) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cur := a.load()
+	if _, ok := cur.compact.m[obj]; !ok {
+		return
+	}
+	h := cur.clone()
+	h.Remove(obj)
+	a.v.Store(h)
+}
+
+// AddAll adds the objects to the hash in order. The new snapshot is built
+// with a single copy.
+func (a *AtomicHash[T]) AddAll(objs []T) {
+	a.Update(func(h *Hash[T]) {
+		h.AddAll(objs)
+	})
+}
+
+// RemoveAll removes the objects from the hash in order. The new snapshot is
+// built with a single copy.
+func (a *AtomicHash[T]) RemoveAll(objs []T) {
+	a.Update(func(h *Hash[T]) {
+		h.RemoveAll(objs)
+	})
+}
+
+// ReplaceAll makes objs the exact content of the hash with the fewest changes.
+// The new snapshot is built with a single copy.
+func (a *AtomicHash[T]) ReplaceAll(objs []T) {
+	a.Update(func(h *Hash[T]) {
+		h.ReplaceAll(objs)
+	})
+}
+
+// Shrink removes all empty slots from the hash.
+func (a *AtomicHash[T]) Shrink() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cur := a.load()
+	if len(cur.loose.f) == 0 {
+		return
+	}
+	h := cur.clone()
+	h.Shrink()
+	a.v.Store(h)
+}
+
+// Replace puts a new object into exactly the slots of an old one and reports
+// whether it succeeded.
+func (a *AtomicHash[T]) Replace(old, new T) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cur := a.load()
+	if _, ok := cur.compact.m[old]; !ok {
+		return false
+	}
+	if _, ok := cur.compact.m[new]; ok {
+		return false
+	}
+	h := cur.clone()
+	h.Replace(old, new)
+	a.v.Store(h)
+	return true
+}
+
+// Pin overrides the hash for the key with obj.
+func (a *AtomicHash[T]) Pin(key uint64, obj T) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cur := a.load()
+	if _, ok := cur.compact.m[obj]; !ok {
+		return ErrObjectNotFound
+	}
+	if v, ok := cur.Pinned(key); ok && v == obj {
+		return nil
+	}
+	h := cur.clone()
+	err := h.Pin(key, obj)
+	a.v.Store(h)
+	return err
+}
+
+// Unpin removes the pin of the key and reports whether the key was pinned.
+func (a *AtomicHash[T]) Unpin(key uint64) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cur := a.load()
+	if _, ok := cur.Pinned(key); !ok {
+		return false
+	}
+	h := cur.clone()
+	h.Unpin(key)
+	a.v.Store(h)
+	return true
+}
+
+// Pinned returns the object the key is pinned to, and reports whether the key
+// is pinned.
+func (a *AtomicHash[T]) Pinned(key uint64) (obj T, ok bool) {
+	return a.load().Pinned(key)
+}
+
+// MarkDown marks an object as unhealthy.
+func (a *AtomicHash[T]) MarkDown(obj T) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cur := a.load()
+	if _, ok := cur.compact.m[obj]; !ok || cur.IsDown(obj) {
+		return
+	}
+	h := cur.clone()
+	h.MarkDown(obj)
+	a.v.Store(h)
+}
+
+// MarkUp marks an object as healthy again.
+func (a *AtomicHash[T]) MarkUp(obj T) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cur := a.load()
+	if !cur.IsDown(obj) {
+		return
+	}
+	h := cur.clone()
+	h.MarkUp(obj)
+	a.v.Store(h)
+}
+
+// IsDown reports whether an object is marked as unhealthy.
+func (a *AtomicHash[T]) IsDown(obj T) bool {
+	return a.load().IsDown(obj)
+}
+
+// GetHealthy returns the first healthy object for the key and reports whether
+// it succeeded.
+func (a *AtomicHash[T]) GetHealthy(key uint64) (obj T, ok bool) {
+	return a.load().GetHealthy(key)
+}
+
+// Drain puts an object into the draining state.
+func (a *AtomicHash[T]) Drain(obj T) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cur := a.load()
+	if _, ok := cur.compact.m[obj]; !ok || cur.IsDraining(obj) {
+		return
+	}
+	h := cur.clone()
+	h.Drain(obj)
+	a.v.Store(h)
+}
+
+// IsDraining reports whether an object is in the draining state.
+func (a *AtomicHash[T]) IsDraining(obj T) bool {
+	return a.load().IsDraining(obj)
+}
+
+// Draining returns the draining objects in the order they were drained.
+func (a *AtomicHash[T]) Draining() []T {
+	return a.load().Draining()
+}
+
+// CancelDrain brings a draining object back to the normal state.
+func (a *AtomicHash[T]) CancelDrain(obj T) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cur := a.load()
+	if !cur.IsDraining(obj) {
+		return
+	}
+	h := cur.clone()
+	h.CancelDrain(obj)
+	a.v.Store(h)
+}
+
+// FinishDrain removes a draining object from the hash and reports whether it
+// succeeded.
+func (a *AtomicHash[T]) FinishDrain(obj T) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cur := a.load()
+	if !cur.IsDraining(obj) {
+		return false
+	}
+	h := cur.clone()
+	h.FinishDrain(obj)
+	a.v.Store(h)
+	return true
+}
+
+// GetNew returns the object for the key as if all the draining objects had
+// been removed, and reports whether it succeeded.
+func (a *AtomicHash[T]) GetNew(key uint64) (obj T, ok bool) {
+	return a.load().GetNew(key)
+}
+
+// StickyKeys returns the keys in the given set which are still mapped to the
+// object by Get.
+func (a *AtomicHash[T]) StickyKeys(obj T, keys []uint64) []uint64 {
+	return a.load().StickyKeys(obj, keys)
+}
+
+// OnChange registers fn to be called after every modification of the hash.
+// fn is called before the new snapshot is published, so it must not call any
+// method of a.
+func (a *AtomicHash[T]) OnChange(fn func(Change[T])) {
+	a.Update(func(h *Hash[T]) {
+		h.OnChange(fn)
+	})
+}
+
+// Len returns the number of objects in the hash.
+func (a *AtomicHash[T]) Len() int {
+	return a.load().Len()
+}
+
+// LooseLen returns the size of the inner loose object holder.
+func (a *AtomicHash[T]) LooseLen() int {
+	return a.load().LooseLen()
+}
+
+// Get returns the existing object for the key and reports whether it succeeded.
+func (a *AtomicHash[T]) Get(key uint64) (obj T, ok bool) {
+	return a.load().Get(key)
+}
+
+// GetString hashes the key with the KeyHasher of the hash, then returns the
+// existing object for it and reports whether it succeeded.
+func (a *AtomicHash[T]) GetString(key string) (obj T, ok bool) {
+	return a.load().GetString(key)
+}
+
+// GetBytes hashes the key with the KeyHasher of the hash, then returns the
+// existing object for it and reports whether it succeeded.
+func (a *AtomicHash[T]) GetBytes(key []byte) (obj T, ok bool) {
+	return a.load().GetBytes(key)
+}
+
+// SameOwner reports whether all the keys are mapped to the same object by
+// GetString. All the keys are resolved against the same snapshot.
+func (a *AtomicHash[T]) SameOwner(keys ...string) bool {
+	return a.load().SameOwner(keys...)
+}
+
+// GetN returns up to n distinct objects for the key, ordered by preference.
+func (a *AtomicHash[T]) GetN(key uint64, n int) []T {
+	return a.load().GetN(key, n)
+}
+
+// AppendN appends up to n distinct objects for the key to dst, ordered by
+// preference, and returns the extended slice.
+func (a *AtomicHash[T]) AppendN(dst []T, key uint64, n int) []T {
+	return a.load().AppendN(dst, key, n)
+}
+
+// GetBatch resolves keys[i] into out[i] and reports the result of each key in
+// ok. All the keys are resolved against the same snapshot.
+func (a *AtomicHash[T]) GetBatch(keys []uint64, out []T, ok []bool) []bool {
+	return a.load().GetBatch(keys, out, ok)
+}
+
+// GetNDiverse is like GetN, but spreads the objects over the failure domains
+// reported by domain.
+func (a *AtomicHash[T]) GetNDiverse(key uint64, n int, domain func(T) string) []T {
+	return a.load().GetNDiverse(key, n, domain)
+}
+
+// Shard returns the shuffle shard of a tenant.
+func (a *AtomicHash[T]) Shard(tenantID uint64, size int) []T {
+	return a.load().Shard(tenantID, size)
+}
+
+// GetInShard returns the object for the key among the shuffle shard of a
+// tenant and reports whether it succeeded.
+func (a *AtomicHash[T]) GetInShard(tenantID uint64, size int, key uint64) (obj T, ok bool) {
+	return a.load().GetInShard(tenantID, size, key)
+}
+
+// All returns all the objects in the hash.
+func (a *AtomicHash[T]) All() []T {
+	return a.load().All()
+}
+
+// Random returns a random object and reports whether it succeeded.
+func (a *AtomicHash[T]) Random() (obj T, ok bool) {
+	return a.load().Random()
+}
+
+// RandomFrom returns a random object chosen with r and reports whether it
+// succeeded. r must not be shared by concurrent callers.
+func (a *AtomicHash[T]) RandomFrom(r Intner) (obj T, ok bool) {
+	return a.load().RandomFrom(r)
+}
+
+// RandomN returns n distinct random objects.
+func (a *AtomicHash[T]) RandomN(n int) []T {
+	return a.load().RandomN(n)
+}
+
+// RandomNFrom is like RandomN but chooses the objects with r.
+func (a *AtomicHash[T]) RandomNFrom(r Intner, n int) []T {
+	return a.load().RandomNFrom(r, n)
+}
+
+// RandomExcept returns a random object other than the excluded ones and
+// reports whether it succeeded.
+func (a *AtomicHash[T]) RandomExcept(exclude ...T) (obj T, ok bool) {
+	return a.load().RandomExcept(exclude...)
+}
+
+// RandomExceptFrom is like RandomExcept but chooses the object with r.
+func (a *AtomicHash[T]) RandomExceptFrom(r Intner, exclude ...T) (obj T, ok bool) {
+	return a.load().RandomExceptFrom(r, exclude...)
+}
+
+// RandomWeighted returns a random object with a probability proportional to
+// its weight and reports whether it succeeded.
+func (a *AtomicHash[T]) RandomWeighted() (obj T, ok bool) {
+	return a.load().RandomWeighted()
+}
+
+// RandomWeightedFrom is like RandomWeighted but chooses the object with r.
+func (a *AtomicHash[T]) RandomWeightedFrom(r Intner) (obj T, ok bool) {
+	return a.load().RandomWeightedFrom(r)
+}
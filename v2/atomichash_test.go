@@ -0,0 +1,205 @@
+package doublejump
+
+import (
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAtomicHash_Basic(t *testing.T) {
+	a := NewAtomicHash[int]()
+	if _, ok := a.Get(100); ok {
+		t.Fatal("something is wrong with Get")
+	}
+	if _, ok := a.Random(); ok {
+		t.Fatal("ok should be false when a is empty")
+	}
+
+	h := NewHash[int]()
+	for i := 0; i < 10; i++ {
+		a.Add(i)
+		h.Add(i)
+	}
+	a.Add(5)
+	a.Remove(3)
+	h.Remove(3)
+	a.Remove(3)
+	if a.Len() != 9 || a.LooseLen() != 10 || len(a.All()) != 9 {
+		t.Fatal("something is wrong with Add/Remove")
+	}
+	for i := 0; i < 10000; i++ {
+		v1, ok1 := a.Get(uint64(i))
+		v2, ok2 := h.Get(uint64(i))
+		if v1 != v2 || ok1 != ok2 {
+			t.Fatalf("a.Get(%d) != h.Get(%d)", i, i)
+		}
+	}
+
+	a.Shrink()
+	if a.Len() != 9 || a.LooseLen() != 9 {
+		t.Fatal("something is wrong with Shrink")
+	}
+	invariant(a.load(), t)
+
+	a.Update(func(h *Hash[int]) {
+		for i := 100; i < 110; i++ {
+			h.Add(i)
+		}
+	})
+	if a.Len() != 19 {
+		t.Fatal("something is wrong with Update")
+	}
+	invariant(a.load(), t)
+}
+
+func TestAtomicHash_Snapshot(t *testing.T) {
+	a := NewAtomicHash[int]()
+	for i := 0; i < 10; i++ {
+		a.Add(i)
+	}
+
+	old := a.load()
+	m := make(map[uint64]int)
+	for i := 0; i < 10000; i++ {
+		m[uint64(i)], _ = old.Get(uint64(i))
+	}
+
+	a.Remove(2)
+	a.Remove(5)
+	a.Add(20)
+	a.Shrink()
+	if old.Len() != 10 || old.LooseLen() != 10 {
+		t.Fatal("the old snapshot should not change")
+	}
+	invariant(old, t)
+	for k, v := range m {
+		if obj, _ := old.Get(k); obj != v {
+			t.Fatal("the old snapshot should not change")
+		}
+	}
+}
+
+func TestAtomicHash_Weighted(t *testing.T) {
+	a := NewAtomicHash[int]()
+	for i := 0; i < 10; i++ {
+		a.AddWeighted(i, i%3+1)
+	}
+	a.Remove(4)
+	old := a.load()
+	invariant(old, t)
+
+	a.SetWeight(2, 5)
+	a.Shrink()
+	a.SetWeight(8, 1)
+	if a.Weight(2) != 5 || a.Weight(8) != 1 || old.Weight(2) != 3 || old.Weight(8) != 3 {
+		t.Fatal("something is wrong with SetWeight")
+	}
+	invariant(old, t)
+	invariant(a.load(), t)
+}
+
+func TestAtomicHash_GetAllocs(t *testing.T) {
+	a := NewAtomicHash[string]()
+	for i := 0; i < 100; i++ {
+		a.Add(string(rune('a' + i)))
+	}
+	a.Remove("c")
+
+	var key uint64
+	allocs := testing.AllocsPerRun(1000, func() {
+		key++
+		a.Get(key)
+	})
+	if allocs != 0 {
+		t.Fatalf("Get should not allocate. allocs: %v", allocs)
+	}
+}
+
+//gocyclo:ignore
+func TestAtomicHash_Concurrent(t *testing.T) {
+	const numStable = 10
+	const numVolatile = 100
+	a := NewAtomicHash[int]()
+	for i := 0; i < numStable; i++ {
+		a.Add(i)
+	}
+
+	var stop int32
+	var wg sync.WaitGroup
+	chErr := make(chan string, 64)
+	numReaders := runtime.NumCPU()
+	if numReaders < 2 {
+		numReaders = 2
+	}
+	for i := 0; i < numReaders; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for atomic.LoadInt32(&stop) == 0 {
+				for j := 0; j < 1000; j++ {
+					if _, ok := a.Get(r.Uint64()); !ok {
+						chErr <- "something is wrong with Get"
+						return
+					}
+				}
+				if _, ok := a.GetNew(r.Uint64()); !ok {
+					chErr <- "something is wrong with GetNew"
+					return
+				}
+				if _, ok := a.Random(); !ok {
+					chErr <- "something is wrong with Random"
+					return
+				}
+				if len(a.All()) < numStable {
+					chErr <- "stable objects are missing"
+					return
+				}
+			}
+		}(int64(i))
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		obj := numStable + r.Intn(numVolatile)
+		switch r.Intn(10) {
+		case 0:
+			a.Shrink()
+		case 1:
+			a.Drain(obj)
+		case 2:
+			a.FinishDrain(obj)
+		case 3, 4:
+			a.Remove(obj)
+		default:
+			a.Add(obj)
+		}
+	}
+	atomic.StoreInt32(&stop, 1)
+	wg.Wait()
+
+	select {
+	case msg := <-chErr:
+		t.Fatal(msg)
+	default:
+	}
+	invariant(a.load(), t)
+}
+
+func BenchmarkAtomicHash_Get(b *testing.B) {
+	a := NewAtomicHash[int]()
+	for i := 0; i < 1000; i++ {
+		a.Add(i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var key uint64
+		for pb.Next() {
+			key++
+			a.Get(key)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package doublejump
+
+import (
+	"sort"
+)
+
+// AddAll adds the objects to the hash in order. The objects already in the
+// hash are skipped. The observers are notified once for the whole batch.
+func (h *Hash[T]) AddAll(objs []T) {
+	var added []T
+	for _, obj := range objs {
+		if h.add(obj, 1) {
+			added = append(added, obj)
+		}
+	}
+	if len(added) > 0 {
+		h.redrain()
+		if len(h.observers) > 0 {
+			h.notify(Change[T]{Added: added})
+		}
+	}
+}
+
+// RemoveAll removes the objects from the hash in order. The objects not in the
+// hash are skipped. The observers are notified once for the whole batch.
+func (h *Hash[T]) RemoveAll(objs []T) {
+	var removed []T
+	var unpinned []uint64
+	for _, obj := range objs {
+		if keys, ok := h.remove(obj); ok {
+			removed = append(removed, obj)
+			unpinned = append(unpinned, keys...)
+		}
+	}
+	if len(removed) > 0 {
+		h.redrain()
+		if len(h.observers) > 0 {
+			h.notify(Change[T]{Removed: removed, Unpinned: unpinned})
+		}
+	}
+}
+
+// ReplaceAll makes objs the exact content of the hash with the fewest changes:
+// the objects not in objs are removed, the new ones are added in the order of
+// objs, and the others are left untouched, weights included. The new objects
+// take the loose slots freed by the removal in ascending index order, so the
+// result depends only on the current layout and objs, not on the free slot
+// policy, unless the policy is NoReuse. The observers are notified once.
+func (h *Hash[T]) ReplaceAll(objs []T) {
+	keep := make(map[T]struct{}, len(objs))
+	var added []T
+	for _, obj := range objs {
+		if _, ok := keep[obj]; ok {
+			continue
+		}
+		keep[obj] = struct{}{}
+		if _, ok := h.compact.m[obj]; !ok {
+			added = append(added, obj)
+		}
+	}
+	var removed []T
+	for _, obj := range h.compact.a {
+		if _, ok := keep[obj]; !ok {
+			removed = append(removed, obj)
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+
+	var freed []int
+	var unpinned []uint64
+	for _, obj := range removed {
+		if h.loose.policy != NoReuse {
+			freed = append(freed, h.loose.m[obj])
+			freed = append(freed, h.loose.x[obj]...)
+		}
+		keys, _ := h.remove(obj)
+		unpinned = append(unpinned, keys...)
+	}
+	sort.Ints(freed)
+	for i, obj := range added {
+		if i < len(freed) {
+			h.loose.addAt(obj, freed[i])
+			h.compact.add(obj)
+		} else {
+			h.add(obj, 1)
+		}
+	}
+	h.redrain()
+	if len(h.observers) > 0 {
+		h.notify(Change[T]{Added: added, Removed: removed, Unpinned: unpinned})
+	}
+}
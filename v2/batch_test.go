@@ -0,0 +1,165 @@
+package doublejump
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestHash_AddAll(t *testing.T) {
+	h1 := NewHash[int]()
+	h2 := NewHash[int]()
+	var changes []Change[int]
+	h2.OnChange(func(c Change[int]) {
+		changes = append(changes, c)
+	})
+
+	h1.Add(5)
+	h2.Add(5)
+	changes = nil
+	objs := []int{3, 1, 4, 1, 5, 9, 2, 6}
+	for _, obj := range objs {
+		h1.Add(obj)
+	}
+	h2.AddAll(objs)
+	invariant(h2, t)
+	if err := checkSameLayout(h1, h2); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || !reflect.DeepEqual(changes[0], Change[int]{Added: []int{3, 1, 4, 9, 2, 6}}) {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+
+	changes = nil
+	h2.AddAll([]int{1, 2, 3})
+	h2.AddAll(nil)
+	if len(changes) != 0 {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+}
+
+func TestHash_RemoveAll(t *testing.T) {
+	h1 := NewHash[int]()
+	h2 := NewHash[int]()
+	churn(h1, 1)
+	churn(h2, 1)
+	var changes []Change[int]
+	h2.OnChange(func(c Change[int]) {
+		changes = append(changes, c)
+	})
+
+	objs := []int{7, 200, 3, 7, 42, 1}
+	var expected []int
+	for _, obj := range objs {
+		if h1.Weight(obj) > 0 {
+			expected = append(expected, obj)
+		}
+		h1.Remove(obj)
+	}
+	h2.RemoveAll(objs)
+	invariant(h2, t)
+	if err := checkSameLayout(h1, h2); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || !reflect.DeepEqual(changes[0], Change[int]{Removed: expected}) {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+}
+
+func TestHash_ReplaceAll(t *testing.T) {
+	h := NewHash[int]()
+	for i := 0; i < 100; i++ {
+		h.Add(i)
+	}
+	h.SetWeight(50, 3)
+	var changes []Change[int]
+	h.OnChange(func(c Change[int]) {
+		changes = append(changes, c)
+	})
+
+	var objs []int
+	var freed []int
+	for i := 0; i < 100; i++ {
+		if i%10 == 7 {
+			idx, _, _ := h.SlotOf(i)
+			freed = append(freed, idx)
+			continue
+		}
+		objs = append(objs, i)
+	}
+	added := []int{109, 103, 100, 105, 108, 101, 104, 102, 106, 107}
+	objs = append(objs, added...)
+	sort.Ints(freed)
+
+	owners := make(map[uint64]int)
+	for i := 0; i < 10000; i++ {
+		key := uint64(i) * 0x9e3779b97f4a7c15
+		if obj, _ := h.Get(key); obj%10 != 7 {
+			owners[key] = obj
+		}
+	}
+
+	h.ReplaceAll(objs)
+	invariant(h, t)
+	if h.Len() != 100 || h.LooseLen() != 102 || h.Weight(50) != 3 {
+		t.Fatal("ReplaceAll does not work as expected")
+	}
+	for i, obj := range added {
+		if idx, _, _ := h.SlotOf(obj); idx != freed[i] {
+			t.Fatalf("the new objects should take the freed slots in ascending order. obj: %d, idx: %d", obj, idx)
+		}
+	}
+	for key, obj := range owners {
+		if v, _ := h.Get(key); v != obj {
+			t.Fatalf("the keys of the remaining objects should not move. key: %d, obj: %d, v: %d", key, obj, v)
+		}
+	}
+	if len(changes) != 1 || len(changes[0].Added) != 10 || len(changes[0].Removed) != 10 {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+
+	changes = nil
+	h.ReplaceAll(objs)
+	if len(changes) != 0 {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+	h.ReplaceAll(nil)
+	invariant(h, t)
+	if h.Len() != 0 {
+		t.Fatal("ReplaceAll(nil) should remove all the objects")
+	}
+}
+
+func TestHash_ReplaceAllDeterministic(t *testing.T) {
+	h1 := NewHash[int]()
+	h2 := NewHashWithOptions[int](WithFreeSlotPolicy(ReuseFirstFreed))
+	h3 := NewHashWithOptions[int](WithFreeSlotPolicy(ReuseLowest))
+	churn(h1, 1)
+	data, err := h1.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range []*Hash[int]{h2, h3} {
+		if err := h.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	objs := []int{1000, 1001}
+	for _, obj := range h1.All() {
+		if obj%3 != 0 {
+			objs = append(objs, obj)
+		}
+	}
+	objs = append(objs, 1002)
+	for _, h := range []*Hash[int]{h1, h2, h3} {
+		h.ReplaceAll(objs)
+		invariant(h, t)
+	}
+	if err := checkSameLayout(h1, h2); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSameLayout(h1, h3); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,58 @@
+package doublejump
+
+import (
+	"github.com/dgryski/go-jump"
+)
+
+// GetBatch resolves keys[i] into out[i], exactly as Get would, and returns ok
+// with ok[i] reporting whether keys[i] was resolved. Only the first
+// min(len(keys), len(out)) keys are resolved. ok is reused if it has enough
+// capacity, so GetBatch does not allocate when the caller passes one. It is
+// faster than calling Get in a loop because the holders are loaded once and
+// the bounds checks are hoisted out of the loop.
+func (h *Hash[T]) GetBatch(keys []uint64, out []T, ok []bool) []bool {
+	if len(out) < len(keys) {
+		keys = keys[:len(out)]
+	}
+	out = out[:len(keys)]
+	if cap(ok) < len(keys) {
+		ok = make([]bool, len(keys))
+	} else {
+		ok = ok[:len(keys)]
+	}
+
+	slots := h.compact.s
+	c := len(slots)
+	if c == 0 {
+		var zero T
+		for i := range out {
+			out[i] = zero
+			ok[i] = false
+		}
+		return ok
+	}
+
+	loose := h.loose.a
+	n := len(loose)
+	mul := h.compact.mul
+	pins := h.pins
+	for i, key := range keys {
+		if len(pins) > 0 {
+			if obj, found := pins[key]; found {
+				out[i] = obj
+				ok[i] = true
+				continue
+			}
+		}
+		if n > 0 {
+			if slot := loose[jump.Hash(key, n)]; slot.b {
+				out[i] = slot.v
+				ok[i] = true
+				continue
+			}
+		}
+		out[i] = slots[jump.Hash(key*mul, c)]
+		ok[i] = true
+	}
+	return ok
+}
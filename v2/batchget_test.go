@@ -0,0 +1,91 @@
+package doublejump
+
+import (
+	"testing"
+)
+
+func TestHash_GetBatch(t *testing.T) {
+	h := NewHash[int]()
+	keys := make([]uint64, 10000)
+	for i := range keys {
+		keys[i] = uint64(i) * 0x9e3779b97f4a7c15
+	}
+	out := make([]int, len(keys))
+	ok := make([]bool, len(keys))
+	for i := range out {
+		out[i] = 1
+	}
+	ok = h.GetBatch(keys, out, ok)
+	for i := range keys {
+		if ok[i] || out[i] != 0 {
+			t.Fatalf("GetBatch should resolve nothing when h is empty. i: %d", i)
+		}
+	}
+
+	churn(h, 1)
+	if len(h.loose.f) == 0 || len(h.compact.x) == 0 {
+		t.Fatal("the test case is too weak")
+	}
+	ok = h.GetBatch(keys, out, ok)
+	if len(ok) != len(keys) {
+		t.Fatalf("len(ok) != len(keys). len(ok): %d", len(ok))
+	}
+	for i, key := range keys {
+		if obj, found := h.Get(key); out[i] != obj || ok[i] != found {
+			t.Fatalf("GetBatch disagrees with Get. key: %d, out[i]: %d, obj: %d", key, out[i], obj)
+		}
+	}
+
+	if ok := h.GetBatch(keys, out[:10], nil); len(ok) != 10 {
+		t.Fatalf("GetBatch should stop at the end of out. len(ok): %d", len(ok))
+	}
+	if ok := h.GetBatch(keys[:10], out, ok); len(ok) != 10 {
+		t.Fatalf("GetBatch should stop at the end of keys. len(ok): %d", len(ok))
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		h.GetBatch(keys, out, ok)
+	})
+	if allocs != 0 {
+		t.Fatalf("GetBatch should not allocate. allocs: %v", allocs)
+	}
+}
+
+func benchmarkHash(numObjects int) (*Hash[int], []uint64) {
+	h := NewHash[int]()
+	for i := 0; i < numObjects*2; i++ {
+		h.Add(i)
+	}
+	for i := 0; i < numObjects*2; i += 2 {
+		h.Remove(i)
+	}
+	keys := make([]uint64, 4096)
+	for i := range keys {
+		keys[i] = uint64(i) * 0x9e3779b97f4a7c15
+	}
+	return h, keys
+}
+
+func BenchmarkHash_Get(b *testing.B) {
+	h, keys := benchmarkHash(1000)
+	out := make([]int, len(keys))
+	ok := make([]bool, len(keys))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j, key := range keys {
+			out[j], ok[j] = h.Get(key)
+		}
+	}
+}
+
+func BenchmarkHash_GetBatch(b *testing.B) {
+	h, keys := benchmarkHash(1000)
+	out := make([]int, len(keys))
+	ok := make([]bool, len(keys))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.GetBatch(keys, out, ok)
+	}
+}
@@ -0,0 +1,143 @@
+package doublejump
+
+import (
+	"math"
+
+	"github.com/dgryski/go-jump"
+)
+
+// DefaultLoadFactor is the default load factor used by GetBounded.
+const DefaultLoadFactor = 1.25
+
+type loadTracker[T comparable] struct {
+	m      map[T]int
+	total  int
+	factor float64
+}
+
+func (tracker *loadTracker[T]) forget(obj T) {
+	if c, ok := tracker.m[obj]; ok {
+		tracker.total -= c
+		delete(tracker.m, obj)
+	}
+}
+
+func (tracker *loadTracker[T]) move(from, to T) {
+	if c, ok := tracker.m[from]; ok {
+		tracker.m[to] = c
+		delete(tracker.m, from)
+	}
+}
+
+func (tracker *loadTracker[T]) clone() loadTracker[T] {
+	c := loadTracker[T]{
+		total:  tracker.total,
+		factor: tracker.factor,
+	}
+	if len(tracker.m) > 0 {
+		c.m = make(map[T]int, len(tracker.m))
+		for k, v := range tracker.m {
+			c.m[k] = v
+		}
+	}
+	return c
+}
+
+// SetLoadFactor sets the load factor c used by GetBounded. No object may carry
+// more than c times its fair share of the total load. The default value is
+// DefaultLoadFactor. It panics if c < 1.
+func (h *Hash[T]) SetLoadFactor(c float64) {
+	if c < 1 || math.IsNaN(c) {
+		panic("doublejump: load factor must not be less than 1")
+	}
+	h.load.factor = c
+}
+
+// LoadFactor returns the load factor used by GetBounded.
+func (h *Hash[T]) LoadFactor() float64 {
+	if h.load.factor == 0 {
+		return DefaultLoadFactor
+	}
+	return h.load.factor
+}
+
+// Inc increases the load of an object by 1. It does nothing if the object is
+// not in the hash.
+func (h *Hash[T]) Inc(obj T) {
+	if _, ok := h.compact.m[obj]; !ok {
+		return
+	}
+	if h.load.m == nil {
+		h.load.m = make(map[T]int)
+	}
+	h.load.m[obj]++
+	h.load.total++
+}
+
+// Dec decreases the load of an object by 1. It does nothing if the load of the
+// object is already 0.
+func (h *Hash[T]) Dec(obj T) {
+	c, ok := h.load.m[obj]
+	if !ok {
+		return
+	}
+	if c <= 1 {
+		delete(h.load.m, obj)
+	} else {
+		h.load.m[obj] = c - 1
+	}
+	h.load.total--
+}
+
+// Load returns the load of an object.
+func (h *Hash[T]) Load(obj T) int {
+	return h.load.m[obj]
+}
+
+// TotalLoad returns the total load of all objects.
+func (h *Hash[T]) TotalLoad() int {
+	return h.load.total
+}
+
+// capacity returns the maximum load of an object with the given weight after
+// one more unit of load is placed.
+func (h *Hash[T]) capacity(weight int) int {
+	totalWeight := len(h.compact.s)
+	fair := float64(h.load.total+1) * float64(weight) / float64(totalWeight)
+	return int(math.Ceil(h.LoadFactor() * fair))
+}
+
+func (h *Hash[T]) underCapacity(obj T) bool {
+	return h.load.m[obj] < h.capacity(h.Weight(obj))
+}
+
+// GetBounded returns the object for the key in the manner of consistent hashing
+// with bounded loads (Mirrokni et al.), and reports whether it succeeded. It
+// walks the same probe sequence as GetN and returns the first object whose load
+// is below its capacity, which is the load factor times its fair share of the
+// total load. The result is the same as Get when the owner of the key is not
+// overloaded. GetBounded does not change any load; call Inc after assigning
+// the key and Dec when releasing it.
+func (h *Hash[T]) GetBounded(key uint64) (obj T, ok bool) {
+	n := h.Len()
+	if n == 0 {
+		return obj, false
+	}
+
+	for i, limit := 0, maxProbes(n); i < limit; i++ {
+		if obj, ok = h.probe(key, i); ok && h.underCapacity(obj) {
+			return obj, true
+		}
+	}
+
+	// The sum of all capacities is greater than the total load, so there must
+	// be at least one object below its capacity.
+	offset := int(jump.Hash(key, n))
+	for i := 0; i < n; i++ {
+		obj = h.compact.a[(offset+i)%n]
+		if h.underCapacity(obj) {
+			return obj, true
+		}
+	}
+	return *new(T), false
+}
@@ -0,0 +1,115 @@
+package doublejump
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHash_Load(t *testing.T) {
+	h := NewHash[int]()
+	h.Inc(1)
+	if h.Load(1) != 0 || h.TotalLoad() != 0 {
+		t.Fatal("Inc should do nothing if the object is not in the hash")
+	}
+
+	h.Add(1)
+	h.Add(2)
+	h.Inc(1)
+	h.Inc(1)
+	h.Inc(2)
+	if h.Load(1) != 2 || h.Load(2) != 1 || h.TotalLoad() != 3 {
+		t.Fatal("something is wrong with Inc")
+	}
+	h.Dec(2)
+	h.Dec(2)
+	if h.Load(2) != 0 || h.TotalLoad() != 2 {
+		t.Fatal("something is wrong with Dec")
+	}
+	h.Remove(1)
+	if h.Load(1) != 0 || h.TotalLoad() != 0 {
+		t.Fatal("Remove should reset the load of the object")
+	}
+
+	if h.LoadFactor() != DefaultLoadFactor {
+		t.Fatal("something is wrong with LoadFactor")
+	}
+	h.SetLoadFactor(2)
+	if h.LoadFactor() != 2 {
+		t.Fatal("something is wrong with SetLoadFactor")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("SetLoadFactor should panic when c < 1")
+		}
+	}()
+	h.SetLoadFactor(0.5)
+}
+
+func TestHash_GetBounded(t *testing.T) {
+	h := NewHash[int]()
+	if _, ok := h.GetBounded(100); ok {
+		t.Fatal("ok should be false when h is empty")
+	}
+
+	for i := 0; i < 10; i++ {
+		h.AddWeighted(i, i%2+1)
+	}
+	for key := uint64(0); key < 100; key++ {
+		obj1, _ := h.Get(key)
+		obj2, ok := h.GetBounded(key)
+		if !ok || obj1 != obj2 {
+			t.Fatal("GetBounded should return the same object as Get when nothing is overloaded")
+		}
+	}
+
+	const total = 30000
+	h.SetLoadFactor(1.1)
+	var moved int
+	for key := uint64(0); key < total; key++ {
+		obj, ok := h.GetBounded(key)
+		if !ok {
+			t.Fatal("something is wrong with GetBounded")
+		}
+		if owner, _ := h.Get(key); owner != obj {
+			moved++
+		}
+		if h.Load(obj) >= h.capacity(h.Weight(obj)) {
+			t.Fatalf("the object is overloaded. obj: %d, load: %d", obj, h.Load(obj))
+		}
+		h.Inc(obj)
+	}
+	if moved == 0 {
+		t.Fatal("some keys should be moved away from their owners")
+	}
+
+	totalWeight := float64(len(h.compact.s))
+	for _, obj := range h.All() {
+		fair := total * float64(h.Weight(obj)) / totalWeight
+		if limit := math.Ceil(fair * 1.1); float64(h.Load(obj)) > limit {
+			t.Fatalf("the load of %d exceeds the limit. load: %d, limit: %.0f", obj, h.Load(obj), limit)
+		}
+	}
+
+	h.Dec(3)
+	obj1, _ := h.GetBounded(12345)
+	obj2, _ := h.GetBounded(12345)
+	if obj1 != obj2 {
+		t.Fatal("GetBounded should be deterministic")
+	}
+}
+
+func TestHash_GetBoundedFallback(t *testing.T) {
+	h := NewHash[int]()
+	for i := 0; i < 3; i++ {
+		h.Add(i)
+	}
+	for i := 0; i < 1000; i++ {
+		h.Inc(0)
+		h.Inc(1)
+	}
+	for key := uint64(0); key < 100; key++ {
+		if obj, ok := h.GetBounded(key); !ok || obj != 2 {
+			t.Fatalf("GetBounded should return the only object below its capacity. obj: %d", obj)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package doublejump
+
+import (
+	"math/rand"
+)
+
+// Sampler produces the keys examined by Diff.
+type Sampler interface {
+	// Sample calls fn for each key.
+	Sample(fn func(key uint64))
+}
+
+type keySet []uint64
+
+// NewKeySet returns a Sampler producing exactly the given keys, which makes
+// Diff enumerate them.
+func NewKeySet(keys []uint64) Sampler {
+	return keySet(keys)
+}
+
+func (s keySet) Sample(fn func(key uint64)) {
+	for _, key := range s {
+		fn(key)
+	}
+}
+
+type randomSampler struct {
+	n    int
+	seed int64
+}
+
+// NewRandomSampler returns a Sampler producing n pseudo-random keys generated
+// from the seed. It makes Diff estimate the movement over the whole keyspace.
+func NewRandomSampler(n int, seed int64) Sampler {
+	return randomSampler{n: n, seed: seed}
+}
+
+func (s randomSampler) Sample(fn func(key uint64)) {
+	r := rand.New(rand.NewSource(s.seed))
+	for i := 0; i < s.n; i++ {
+		fn(r.Uint64())
+	}
+}
+
+// Move is a pair of the source and destination objects of remapped keys.
+type Move[T comparable] struct {
+	From T
+	To   T
+}
+
+// DiffReport describes the key movement between two hashes.
+type DiffReport[T comparable] struct {
+	// Total is the number of the examined keys.
+	Total int
+	// Moved is the number of the keys mapped to different objects.
+	Moved int
+	// Moves is the number of the remapped keys of every source and destination
+	// pair. A key unresolvable in one of the hashes is recorded with the zero
+	// value of T on that side.
+	Moves map[Move[T]]int
+}
+
+// Fraction returns the fraction of the remapped keys.
+func (r DiffReport[T]) Fraction() float64 {
+	if r.Total == 0 {
+		return 0
+	}
+	return float64(r.Moved) / float64(r.Total)
+}
+
+// FractionOf returns the fraction of the keys remapped from move.From to move.To.
+func (r DiffReport[T]) FractionOf(move Move[T]) float64 {
+	if r.Total == 0 {
+		return 0
+	}
+	return float64(r.Moves[move]) / float64(r.Total)
+}
+
+// Diff reports how the keys produced by the sampler are remapped when moving
+// from hash a to hash b. Use NewKeySet to enumerate a known set of keys, or
+// NewRandomSampler to estimate the movement statistically.
+func Diff[T comparable](a, b *Hash[T], sampler Sampler) DiffReport[T] {
+	r := DiffReport[T]{Moves: make(map[Move[T]]int)}
+	sampler.Sample(func(key uint64) {
+		r.Total++
+		from, ok1 := a.Get(key)
+		to, ok2 := b.Get(key)
+		if ok1 == ok2 && from == to {
+			return
+		}
+		r.Moved++
+		r.Moves[Move[T]{From: from, To: to}]++
+	})
+	return r
+}
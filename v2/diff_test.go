@@ -0,0 +1,82 @@
+package doublejump
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	h1 := NewHash[int]()
+	for i := 0; i < 10; i++ {
+		h1.Add(i)
+	}
+	if r := Diff(h1, h1, NewRandomSampler(10000, 1)); r.Total != 10000 || r.Moved != 0 || r.Fraction() != 0 {
+		t.Fatal("nothing should move between identical hashes")
+	}
+
+	h2 := h1.clone()
+	h2.Remove(3)
+	r := Diff(h1, h2, NewRandomSampler(100000, 1))
+	if math.Abs(r.Fraction()-0.1) > 0.01 {
+		t.Fatalf("about 10%% of the keys should move. fraction: %.4f", r.Fraction())
+	}
+	var sum float64
+	for move, c := range r.Moves {
+		if move.From != 3 || move.To == 3 || c == 0 {
+			t.Fatalf("only the keys owned by 3 should move. move: %v", move)
+		}
+		sum += r.FractionOf(move)
+	}
+	if math.Abs(sum-r.Fraction()) > 1e-9 {
+		t.Fatal("the fractions of the moves should sum up to the total fraction")
+	}
+
+	h3 := h2.clone()
+	h3.Add(100)
+	r = Diff(h2, h3, NewRandomSampler(100000, 2))
+	for move := range r.Moves {
+		if move.To != 100 {
+			t.Fatalf("only the keys moving to 100 should move. move: %v", move)
+		}
+	}
+}
+
+func TestDiff_KeySet(t *testing.T) {
+	h1 := NewHash[int]()
+	for i := 0; i < 10; i++ {
+		h1.Add(i)
+	}
+	h2 := h1.clone()
+	h2.Remove(5)
+
+	var keys []uint64
+	var moved int
+	for key := uint64(0); key < 1000; key++ {
+		keys = append(keys, key)
+		if obj, _ := h1.Get(key); obj == 5 {
+			moved++
+		}
+	}
+	r := Diff(h1, h2, NewKeySet(keys))
+	if r.Total != len(keys) || r.Moved != moved {
+		t.Fatalf("something is wrong with Diff. total: %d, moved: %d, expected: %d", r.Total, r.Moved, moved)
+	}
+
+	empty := NewHash[int]()
+	r = Diff(h1, empty, NewKeySet(keys))
+	if r.Moved != len(keys) || r.Fraction() != 1 {
+		t.Fatal("all keys should move to an empty hash")
+	}
+	for move := range r.Moves {
+		if move.To != 0 {
+			t.Fatalf("the destination should be the zero value. move: %v", move)
+		}
+	}
+
+	if r := Diff(empty, empty, NewKeySet(keys)); r.Moved != 0 {
+		t.Fatal("nothing should move between empty hashes")
+	}
+	if r := Diff(h1, h2, NewKeySet(nil)); r.Fraction() != 0 || r.FractionOf(Move[int]{}) != 0 {
+		t.Fatal("the fractions should be 0 when no key is examined")
+	}
+}
@@ -0,0 +1,51 @@
+package doublejump
+
+import (
+	"github.com/dgryski/go-jump"
+)
+
+// GetNDiverse is like GetN, but no two objects of the result share a domain as
+// long as there are enough domains; the rest is filled in the order of GetN.
+func (h *Hash[T]) GetNDiverse(key uint64, n int, domain func(T) string) []T {
+	if n > h.Len() {
+		n = h.Len()
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	dst := make([]T, 0, n)
+	domains := make([]string, 0, n)
+	seen := make(map[T]struct{})
+	pick := func(obj T) {
+		if _, ok := seen[obj]; ok {
+			return
+		}
+		seen[obj] = struct{}{}
+		if d := domain(obj); !contains(domains, d) {
+			dst = append(dst, obj)
+			domains = append(domains, d)
+		}
+	}
+	for i, limit := 0, maxProbes(n); len(dst) < n && i < limit; i++ {
+		if obj, ok := h.probe(key, i); ok {
+			pick(obj)
+		}
+	}
+	c := len(h.loose.a)
+	offset := int(jump.Hash(key, c))
+	for i := 0; i < c && len(dst) < n && len(seen) < h.Len(); i++ {
+		if opt := h.loose.a[(offset+i)%c]; opt.b {
+			pick(opt.v)
+		}
+	}
+	if len(dst) < n {
+		for i, limit := 0, maxProbes(n); len(dst) < n && i < limit; i++ {
+			if obj, ok := h.probe(key, i); ok && !contains(dst, obj) {
+				dst = append(dst, obj)
+			}
+		}
+		dst = h.appendRest(dst, 0, key, n)
+	}
+	return dst
+}
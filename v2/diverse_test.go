@@ -0,0 +1,144 @@
+package doublejump
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHash_GetNDiverse(t *testing.T) {
+	domain := func(obj int) string {
+		if obj == 0 {
+			return "rare"
+		}
+		return fmt.Sprintf("d%d", obj%4)
+	}
+	h := NewHash[int]()
+	if a := h.GetNDiverse(1, 3, domain); len(a) != 0 {
+		t.Fatal("GetNDiverse should return nothing when h is empty")
+	}
+	for i := 0; i < 100; i++ {
+		h.Add(i)
+	}
+
+	var rare int
+	for key := uint64(0); key < 10000; key++ {
+		a := h.GetNDiverse(key, 3, domain)
+		if len(a) != 3 {
+			t.Fatalf("len(a) != 3. len(a): %d", len(a))
+		}
+		if obj, _ := h.Get(key); a[0] != obj {
+			t.Fatalf("a[0] != h.Get(key). a[0]: %d, obj: %d", a[0], obj)
+		}
+		domains := make(map[string]struct{})
+		for _, obj := range a {
+			domains[domain(obj)] = struct{}{}
+		}
+		if len(domains) != 3 {
+			t.Fatalf("the objects should be in different domains. a: %v", a)
+		}
+
+		b := h.GetNDiverse(key, 8, domain)
+		if len(b) != 8 {
+			t.Fatalf("len(b) != 8. len(b): %d", len(b))
+		}
+		domains = make(map[string]struct{})
+		for i, obj := range b {
+			if contains(b[:i], obj) {
+				t.Fatalf("the objects returned by GetNDiverse should be distinct. b: %v", b)
+			}
+			if i < 5 {
+				domains[domain(obj)] = struct{}{}
+			}
+		}
+		if len(domains) != 5 {
+			t.Fatalf("every domain should appear at the head. b: %v", b)
+		}
+		if contains(b, 0) {
+			rare++
+		}
+		c := h.GetNDiverse(key, 8, domain)
+		for i := range b {
+			if b[i] != c[i] {
+				t.Fatalf("GetNDiverse should be deterministic. b: %v, c: %v", b, c)
+			}
+		}
+	}
+	if rare != 10000 {
+		t.Fatalf("the rare domain should always be picked. rare: %d", rare)
+	}
+	if a := h.GetNDiverse(1, 1000, domain); len(a) != 100 {
+		t.Fatalf("GetNDiverse should return all objects when n > h.Len(). len(a): %d", len(a))
+	}
+}
+
+func TestHash_GetNDiverseStable(t *testing.T) {
+	domain := func(obj int) string {
+		return fmt.Sprintf("d%d", obj%10)
+	}
+	h := NewHash[int]()
+	for i := 0; i < 100; i++ {
+		h.Add(i)
+	}
+
+	const total = 10000
+	lists := make([][]int, total)
+	for key := range lists {
+		lists[key] = h.GetNDiverse(uint64(key), 3, domain)
+	}
+	h.Remove(17)
+	var changed int
+	for key, a := range lists {
+		b := h.GetNDiverse(uint64(key), 3, domain)
+		if contains(b, 17) {
+			t.Fatalf("17 should not be in the list. b: %v", b)
+		}
+		if contains(a, 17) {
+			continue
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				changed++
+				break
+			}
+		}
+	}
+	if changed > total/50 {
+		t.Fatalf("too many lists without 17 have changed. changed: %d", changed)
+	}
+}
+
+func TestHash_GetNDiverseDomainCalls(t *testing.T) {
+	var calls int
+	domain := func(obj int) string {
+		calls++
+		return fmt.Sprintf("d%d", obj%3)
+	}
+	h := NewHash[int]()
+	for i := 0; i < 1000; i++ {
+		h.AddWeighted(i, i%2+1)
+	}
+	for i := 0; i < 1000; i += 7 {
+		h.Remove(i)
+	}
+
+	for key := uint64(0); key < 100; key++ {
+		calls = 0
+		a := h.GetNDiverse(key, 5, domain)
+		if len(a) != 5 {
+			t.Fatalf("len(a) != 5. len(a): %d", len(a))
+		}
+		if calls > h.Len() {
+			t.Fatalf("domain should be called at most once per object. calls: %d", calls)
+		}
+	}
+
+	h = NewHash[int]()
+	for i := 0; i < 10; i++ {
+		h.Add(i)
+	}
+	calls = 0
+	h.GetNDiverse(1, 8, domain)
+	if calls != 10 {
+		t.Fatalf("domain should be called once per object. calls: %d", calls)
+	}
+}
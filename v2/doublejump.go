@@ -2,11 +2,28 @@
 package doublejump
 
 import (
-	"math/rand"
+	"errors"
+	"math"
 
 	"github.com/dgryski/go-jump"
 )
 
+var (
+	// ErrObjectExists is returned when adding an object already in the hash.
+	ErrObjectExists = errors.New("doublejump: the object already exists")
+	// ErrSlotOccupied is returned when adding an object to an occupied slot.
+	ErrSlotOccupied = errors.New("doublejump: the slot is occupied")
+	// ErrInvalidSlot is returned when the slot index is negative or not less
+	// than MaxLooseLen.
+	ErrInvalidSlot = errors.New("doublejump: invalid slot")
+	// ErrObjectNotFound is returned when the object is not in the hash.
+	ErrObjectNotFound = errors.New("doublejump: the object does not exist")
+)
+
+// MaxLooseLen is the maximum size of the inner loose object holder, which is
+// the maximum number of buckets supported by jump.Hash.
+const MaxLooseLen = math.MaxInt32
+
 type optional[T comparable] struct {
 	b bool
 	v T
@@ -16,6 +33,38 @@ type looseHolder[T comparable] struct {
 	a []optional[T]
 	m map[T]int
 	f []int
+	x map[T][]int
+
+	policy FreeSlotPolicy
+}
+
+func (holder *looseHolder[T]) take(obj T) int {
+	n := len(holder.f)
+	if n == 0 || holder.policy == NoReuse {
+		holder.a = append(holder.a, optional[T]{v: obj, b: true})
+		return len(holder.a) - 1
+	}
+
+	i := n - 1
+	switch holder.policy {
+	case ReuseFirstFreed:
+		i = 0
+	case ReuseLowest:
+		for j, idx := range holder.f {
+			if idx < holder.f[i] {
+				i = j
+			}
+		}
+	}
+	idx := holder.f[i]
+	holder.f = append(holder.f[:i], holder.f[i+1:]...)
+	holder.a[idx] = optional[T]{v: obj, b: true}
+	return idx
+}
+
+func (holder *looseHolder[T]) release(idx int) {
+	holder.a[idx] = optional[T]{}
+	holder.f = append(holder.f, idx)
 }
 
 func (holder *looseHolder[T]) add(obj T) {
@@ -23,25 +72,78 @@ func (holder *looseHolder[T]) add(obj T) {
 		return
 	}
 
-	if n := len(holder.f); n == 0 {
+	holder.m[obj] = holder.take(obj)
+}
+
+func (holder *looseHolder[T]) addAt(obj T, slot int) {
+	if slot >= len(holder.a) {
+		holder.grow(slot)
 		holder.a = append(holder.a, optional[T]{v: obj, b: true})
-		holder.m[obj] = len(holder.a) - 1
 	} else {
-		idx := holder.f[n-1]
-		holder.f = holder.f[:n-1]
-		holder.a[idx] = optional[T]{v: obj, b: true}
-		holder.m[obj] = idx
+		for i, idx := range holder.f {
+			if idx == slot {
+				holder.f = append(holder.f[:i], holder.f[i+1:]...)
+				break
+			}
+		}
+		holder.a[slot] = optional[T]{v: obj, b: true}
+	}
+	holder.m[obj] = slot
+}
+
+func (holder *looseHolder[T]) grow(n int) {
+	for i := len(holder.a); i < n; i++ {
+		holder.a = append(holder.a, optional[T]{})
+		holder.f = append(holder.f, i)
+	}
+}
+
+func (holder *looseHolder[T]) addExtra(obj T) {
+	if holder.x == nil {
+		holder.x = make(map[T][]int)
+	}
+	holder.x[obj] = append(holder.x[obj], holder.take(obj))
+}
+
+func (holder *looseHolder[T]) removeExtra(obj T) {
+	extra := holder.x[obj]
+	n := len(extra)
+	if n == 0 {
+		return
+	}
+
+	holder.release(extra[n-1])
+	if n == 1 {
+		delete(holder.x, obj)
+	} else {
+		holder.x[obj] = extra[:n-1]
 	}
 }
 
 func (holder *looseHolder[T]) remove(obj T) {
 	if idx, ok := holder.m[obj]; ok {
-		holder.a[idx] = optional[T]{}
-		holder.f = append(holder.f, idx)
+		for len(holder.x[obj]) > 0 {
+			holder.removeExtra(obj)
+		}
+		holder.release(idx)
 		delete(holder.m, obj)
 	}
 }
 
+func (holder *looseHolder[T]) replace(old, new T) {
+	idx := holder.m[old]
+	holder.a[idx].v = new
+	holder.m[new] = idx
+	delete(holder.m, old)
+	if extra, ok := holder.x[old]; ok {
+		for _, idx := range extra {
+			holder.a[idx].v = new
+		}
+		holder.x[new] = extra
+		delete(holder.x, old)
+	}
+}
+
 func (holder *looseHolder[T]) get(key uint64) (T, bool) {
 	var defVal T
 	n := len(holder.a)
@@ -57,25 +159,66 @@ func (holder *looseHolder[T]) get(key uint64) (T, bool) {
 	}
 }
 
+func (holder *looseHolder[T]) clone() looseHolder[T] {
+	c := looseHolder[T]{
+		a: make([]optional[T], len(holder.a)),
+		m: make(map[T]int, len(holder.m)),
+		f: make([]int, len(holder.f)),
+
+		policy: holder.policy,
+	}
+	copy(c.a, holder.a)
+	copy(c.f, holder.f)
+	for k, v := range holder.m {
+		c.m[k] = v
+	}
+	if len(holder.x) > 0 {
+		c.x = make(map[T][]int, len(holder.x))
+		for k, v := range holder.x {
+			c.x[k] = append([]int(nil), v...)
+		}
+	}
+	return c
+}
+
 func (holder *looseHolder[T]) shrink() {
 	if len(holder.f) == 0 {
 		return
 	}
 
 	var a []optional[T]
-	for _, opt := range holder.a {
+	indices := make([]int, len(holder.a))
+	for i, opt := range holder.a {
 		if opt.b {
 			a = append(a, opt)
-			holder.m[opt.v] = len(a) - 1
+			indices[i] = len(a) - 1
+		}
+	}
+	for obj, idx := range holder.m {
+		holder.m[obj] = indices[idx]
+	}
+	for _, extra := range holder.x {
+		for i, idx := range extra {
+			extra[i] = indices[idx]
 		}
 	}
 	holder.a = a
 	holder.f = nil
 }
 
+// compactHolder holds the objects in a, and their slots in s. Every object
+// occupies weight slots of s: the primary slot recorded in p and the extra
+// slots recorded in x. Keeping all the slots in one array lets a removal swap
+// single slots with the tail, so only the keys of the removed slots and the
+// tail slot are remapped.
 type compactHolder[T comparable] struct {
 	a []T
 	m map[T]int
+	s []T
+	p map[T]int
+	x map[T][]int
+
+	mul uint64
 }
 
 func (holder *compactHolder[T]) add(obj T) {
@@ -85,10 +228,65 @@ func (holder *compactHolder[T]) add(obj T) {
 
 	holder.a = append(holder.a, obj)
 	holder.m[obj] = len(holder.a) - 1
+	holder.s = append(holder.s, obj)
+	holder.p[obj] = len(holder.s) - 1
+}
+
+func (holder *compactHolder[T]) addExtra(obj T) {
+	if holder.x == nil {
+		holder.x = make(map[T][]int)
+	}
+	holder.s = append(holder.s, obj)
+	holder.x[obj] = append(holder.x[obj], len(holder.s)-1)
+}
+
+// removeSlot moves the tail slot into the slot idx and shrinks s by one.
+func (holder *compactHolder[T]) removeSlot(idx int) {
+	newLen := len(holder.s) - 1
+	if idx != newLen {
+		tail := holder.s[newLen]
+		holder.s[idx] = tail
+		if holder.p[tail] == newLen {
+			holder.p[tail] = idx
+		} else {
+			extra := holder.x[tail]
+			for i, v := range extra {
+				if v == newLen {
+					extra[i] = idx
+					break
+				}
+			}
+		}
+	}
+	var defVal T
+	holder.s[newLen] = defVal
+	holder.s = holder.s[:newLen]
+}
+
+func (holder *compactHolder[T]) removeExtra(obj T) {
+	extra := holder.x[obj]
+	n := len(extra)
+	if n == 0 {
+		return
+	}
+
+	idx := extra[n-1]
+	if n == 1 {
+		delete(holder.x, obj)
+	} else {
+		holder.x[obj] = extra[:n-1]
+	}
+	holder.removeSlot(idx)
 }
 
 func (holder *compactHolder[T]) remove(obj T) {
 	if idx, ok := holder.m[obj]; ok {
+		for len(holder.x[obj]) > 0 {
+			holder.removeExtra(obj)
+		}
+		holder.removeSlot(holder.p[obj])
+		delete(holder.p, obj)
+
 		newLen := len(holder.a) - 1
 		tail := holder.a[newLen]
 		holder.a[idx] = tail
@@ -100,15 +298,59 @@ func (holder *compactHolder[T]) remove(obj T) {
 	}
 }
 
+func (holder *compactHolder[T]) replace(old, new T) {
+	idx := holder.m[old]
+	holder.a[idx] = new
+	holder.m[new] = idx
+	delete(holder.m, old)
+	slot := holder.p[old]
+	holder.s[slot] = new
+	holder.p[new] = slot
+	delete(holder.p, old)
+	if extra, ok := holder.x[old]; ok {
+		for _, idx := range extra {
+			holder.s[idx] = new
+		}
+		holder.x[new] = extra
+		delete(holder.x, old)
+	}
+}
+
 func (holder *compactHolder[T]) get(key uint64) (T, bool) {
 	var defVal T
-	n := len(holder.a)
+	n := len(holder.s)
 	if n == 0 {
 		return defVal, false
 	}
 
-	h := jump.Hash(key*0xc6a4a7935bd1e995, n)
-	return holder.a[h], true
+	h := jump.Hash(key*holder.mul, n)
+	return holder.s[h], true
+}
+
+func (holder *compactHolder[T]) clone() compactHolder[T] {
+	c := compactHolder[T]{
+		a: make([]T, len(holder.a)),
+		m: make(map[T]int, len(holder.m)),
+		s: make([]T, len(holder.s)),
+		p: make(map[T]int, len(holder.p)),
+
+		mul: holder.mul,
+	}
+	copy(c.a, holder.a)
+	for k, v := range holder.m {
+		c.m[k] = v
+	}
+	copy(c.s, holder.s)
+	for k, v := range holder.p {
+		c.p[k] = v
+	}
+	if len(holder.x) > 0 {
+		c.x = make(map[T][]int, len(holder.x))
+		for k, v := range holder.x {
+			c.x[k] = append([]int(nil), v...)
+		}
+	}
+	return c
 }
 
 // Hash is a revamped Google's jump consistent hash. It overcomes the shortcoming of
@@ -118,26 +360,285 @@ func (holder *compactHolder[T]) get(key uint64) (T, bool) {
 type Hash[T comparable] struct {
 	loose   looseHolder[T]
 	compact compactHolder[T]
+	hasher  KeyHasher
+	tags    bool
+	codec   Codec[T]
+	rnd     Intner
+	load    loadTracker[T]
+	down    map[T]struct{}
+	pins    map[uint64]T
+
+	draining []T
+	drained  *Hash[T] // the view of GetNew, rebuilt by every writer
+
+	observers []func(Change[T])
 }
 
 // NewHash creates a new doublejump hash instance.
 func NewHash[T comparable]() *Hash[T] {
-	hash := &Hash[T]{}
-	hash.loose.m = make(map[T]int)
-	hash.compact.m = make(map[T]int)
+	return NewHashWithOptions[T]()
+}
+
+// NewHashWithKeyHasher creates a new doublejump hash instance which uses hasher
+// to turn string and byte-slice keys into uint64 keys.
+func NewHashWithKeyHasher[T comparable](hasher KeyHasher) *Hash[T] {
+	return NewHashWithOptions[T](WithKeyHasher(hasher))
+}
+
+// NewHashWithOptions creates a new doublejump hash instance with options.
+func NewHashWithOptions[T comparable](opts ...Option) *Hash[T] {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	hash := &Hash[T]{hasher: o.hasher, tags: o.hashTags, rnd: o.rnd}
+	hash.loose.m = make(map[T]int, o.capacity)
+	hash.loose.policy = o.policy
+	hash.compact.m = make(map[T]int, o.capacity)
+	hash.compact.p = make(map[T]int, o.capacity)
+	hash.compact.mul = o.multiplier
+	if o.capacity > 0 {
+		hash.loose.a = make([]optional[T], 0, o.capacity)
+		hash.compact.a = make([]T, 0, o.capacity)
+		hash.compact.s = make([]T, 0, o.capacity)
+	}
 	return hash
 }
 
+func (h *Hash[T]) clone() *Hash[T] {
+	return &Hash[T]{
+		loose:   h.loose.clone(),
+		compact: h.compact.clone(),
+		hasher:  h.hasher,
+		tags:    h.tags,
+		codec:   h.codec,
+		rnd:     h.rnd,
+		load:    h.load.clone(),
+		down:    cloneSet(h.down),
+		pins:    clonePins(h.pins),
+
+		draining: append([]T(nil), h.draining...),
+		drained:  h.drained,
+
+		observers: append(([]func(Change[T]))(nil), h.observers...),
+	}
+}
+
 // Add adds an object to the hash.
 func (h *Hash[T]) Add(obj T) {
+	if h.add(obj, 1) {
+		h.redrain()
+		if len(h.observers) > 0 {
+			h.notify(Change[T]{Added: []T{obj}})
+		}
+	}
+}
+
+// AddWeighted adds an object to the hash with the given weight. An object with
+// weight n occupies n slots in the hash and receives about n times as many
+// keys as an object with weight 1. AddWeighted does nothing if the object is
+// already in the hash; use SetWeight to change its weight. It panics if
+// weight < 1.
+func (h *Hash[T]) AddWeighted(obj T, weight int) {
+	if weight < 1 {
+		panic("doublejump: weight must be positive")
+	}
+	if h.add(obj, weight) {
+		h.redrain()
+		if len(h.observers) > 0 {
+			h.notify(Change[T]{Added: []T{obj}})
+		}
+	}
+}
+
+func (h *Hash[T]) add(obj T, weight int) bool {
+	if _, ok := h.compact.m[obj]; ok {
+		return false
+	}
+
 	h.loose.add(obj)
 	h.compact.add(obj)
+	for i := 1; i < weight; i++ {
+		h.loose.addExtra(obj)
+		h.compact.addExtra(obj)
+	}
+	return true
+}
+
+// AddAt adds an object to the given slot of the inner loose object holder,
+// growing the holder with empty slots if necessary. It fails if the object is
+// already in the hash, the slot is occupied, or the slot is out of range.
+// Together with SlotOf and Slots, it allows rebuilding the same unweighted hash
+// in different processes. The keys of the empty slots fall back to the compact
+// holder, which AddAt fills in the order of the calls, so add the objects in
+// the order of their compact indices. Weighted hashes can not be rebuilt this
+// way; use MarshalBinary or MarshalJSON instead.
+func (h *Hash[T]) AddAt(obj T, slot int) error {
+	if slot < 0 || slot >= MaxLooseLen {
+		return ErrInvalidSlot
+	}
+	if _, ok := h.compact.m[obj]; ok {
+		return ErrObjectExists
+	}
+	if slot < len(h.loose.a) && h.loose.a[slot].b {
+		return ErrSlotOccupied
+	}
+
+	var grown int
+	if slot > len(h.loose.a) {
+		grown = slot - len(h.loose.a)
+	}
+	h.loose.addAt(obj, slot)
+	h.compact.add(obj)
+	h.redrain()
+	if len(h.observers) > 0 {
+		h.notify(Change[T]{Added: []T{obj}, Grown: grown})
+	}
+	return nil
+}
+
+// SetWeight changes the weight of an existing object. Only the keys moving to
+// or from the object are remapped. SetWeight does nothing if the object is not
+// in the hash. It panics if weight < 1.
+func (h *Hash[T]) SetWeight(obj T, weight int) {
+	if weight < 1 {
+		panic("doublejump: weight must be positive")
+	}
+	if h.setWeight(obj, weight) {
+		h.redrain()
+		if len(h.observers) > 0 {
+			h.notify(Change[T]{Reweighted: []T{obj}})
+		}
+	}
+}
+
+func (h *Hash[T]) setWeight(obj T, weight int) bool {
+	w := h.Weight(obj)
+	if w == 0 || w == weight {
+		return false
+	}
+
+	for ; w < weight; w++ {
+		h.loose.addExtra(obj)
+		h.compact.addExtra(obj)
+	}
+	for ; w > weight; w-- {
+		h.loose.removeExtra(obj)
+		h.compact.removeExtra(obj)
+	}
+	return true
+}
+
+// Weight returns the weight of an object, or 0 if the object is not in the hash.
+func (h *Hash[T]) Weight(obj T) int {
+	if _, ok := h.compact.m[obj]; !ok {
+		return 0
+	}
+	return len(h.compact.x[obj]) + 1
 }
 
 // Remove removes an object from the hash.
 func (h *Hash[T]) Remove(obj T) {
+	if unpinned, ok := h.remove(obj); ok {
+		h.redrain()
+		if len(h.observers) > 0 {
+			h.notify(Change[T]{Removed: []T{obj}, Unpinned: unpinned})
+		}
+	}
+}
+
+func (h *Hash[T]) remove(obj T) (unpinned []uint64, ok bool) {
+	if _, ok := h.compact.m[obj]; !ok {
+		return nil, false
+	}
+
 	h.loose.remove(obj)
 	h.compact.remove(obj)
+	return h.forget(obj), true
+}
+
+// forget drops the states of an object removed from the hash and returns the
+// keys which were pinned to it.
+func (h *Hash[T]) forget(obj T) (unpinned []uint64) {
+	h.load.forget(obj)
+	delete(h.down, obj)
+	h.undrain(obj)
+	return h.unpinObject(obj)
+}
+
+// Replace puts a new object into exactly the slots of an old one, so that all
+// the keys of the old object go to the new one and no other key moves. It
+// reports whether it succeeded, which requires the old object to be in the
+// hash and the new one not. The load and the pins of the old object follow its
+// keys to the new one, while its draining and down states are dropped, since
+// they belong to the old object.
+func (h *Hash[T]) Replace(old, new T) bool {
+	if _, ok := h.compact.m[old]; !ok {
+		return false
+	}
+	if _, ok := h.compact.m[new]; ok {
+		return false
+	}
+
+	h.loose.replace(old, new)
+	h.compact.replace(old, new)
+	h.load.move(old, new)
+	pinned := h.movePins(old, new)
+	h.forget(old)
+	h.redrain()
+	if len(h.observers) > 0 {
+		h.notify(Change[T]{Added: []T{new}, Removed: []T{old}, Pinned: pinned})
+	}
+	return true
+}
+
+// GrowTo appends empty slots to the inner loose object holder until its size
+// reaches n. Use it with AddAt to recreate the trailing empty slots of a hash.
+// It panics if n > MaxLooseLen.
+func (h *Hash[T]) GrowTo(n int) {
+	if n > MaxLooseLen {
+		panic("doublejump: n must not be greater than MaxLooseLen")
+	}
+	if n <= len(h.loose.a) {
+		return
+	}
+	grown := n - len(h.loose.a)
+	h.loose.grow(n)
+	h.redrain()
+	if len(h.observers) > 0 {
+		h.notify(Change[T]{Grown: grown})
+	}
+}
+
+// SlotOf returns the indices of the slots of an object in the inner loose and
+// compact object holders, and reports whether the object is in the hash. The
+// extra slots of a weighted object are not included.
+func (h *Hash[T]) SlotOf(obj T) (loose, compact int, ok bool) {
+	if _, ok = h.compact.m[obj]; !ok {
+		return -1, -1, false
+	}
+	return h.loose.m[obj], h.compact.p[obj], true
+}
+
+// Slot is a slot of the inner loose object holder.
+type Slot[T comparable] struct {
+	Obj  T
+	Used bool
+}
+
+// Slots returns all the slots of the inner loose object holder, including
+// the empty ones.
+func (h *Hash[T]) Slots() []Slot[T] {
+	n := len(h.loose.a)
+	if n == 0 {
+		return nil
+	}
+	slots := make([]Slot[T], n)
+	for i, opt := range h.loose.a {
+		slots[i] = Slot[T]{Obj: opt.v, Used: opt.b}
+	}
+	return slots
 }
 
 // Len returns the number of objects in the hash.
@@ -150,19 +651,70 @@ func (h *Hash[T]) LooseLen() int {
 	return len(h.loose.a)
 }
 
-// Shrink removes all empty slots from the hash.
+// Shrink removes all empty slots from the hash. Note that the keys of the
+// relocated slots are remapped, and so are the preference lists of GetN, the
+// shuffle shards and the keys of GetInShard, which all depend on the slots.
 func (h *Hash[T]) Shrink() {
+	if len(h.loose.f) == 0 {
+		return
+	}
+	if len(h.observers) == 0 {
+		h.loose.shrink()
+		h.redrain()
+		return
+	}
+
+	var c Change[T]
+	var n int
+	for i, opt := range h.loose.a {
+		if opt.b {
+			if i != n {
+				c.Relocated = append(c.Relocated, Relocation[T]{Obj: opt.v, From: i, To: n})
+			}
+			n++
+		}
+	}
 	h.loose.shrink()
+	h.redrain()
+	if len(c.Relocated) > 0 {
+		h.notify(c)
+	}
 }
 
 // Get returns the existing object for the key and reports whether it succeeded.
+// A pinned key always goes to the object it is pinned to.
 func (h *Hash[T]) Get(key uint64) (obj T, ok bool) {
+	if len(h.pins) > 0 {
+		if obj, ok = h.pins[key]; ok {
+			return obj, true
+		}
+	}
 	if obj, ok = h.loose.get(key); ok {
 		return obj, true
 	}
 	return h.compact.get(key)
 }
 
+// GetString hashes the key with the KeyHasher of the hash, then returns the
+// existing object for it and reports whether it succeeded. Only the hash tag
+// of the key is hashed if the hash is created with WithHashTags.
+func (h *Hash[T]) GetString(key string) (obj T, ok bool) {
+	if h.tags {
+		key = hashTag(key)
+	}
+	return h.Get(h.hasher.HashString(key))
+}
+
+// GetBytes hashes the key with the KeyHasher of the hash, then returns the
+// existing object for it and reports whether it succeeded. Only the hash tag
+// of the key is hashed if the hash is created with WithHashTags.
+func (h *Hash[T]) GetBytes(key []byte) (obj T, ok bool) {
+	if h.tags {
+		key = hashTag(key)
+	}
+	return h.Get(h.hasher.HashBytes(key))
+}
+
 // All returns all the objects in this Hash.
 func (h *Hash[T]) All() []T {
 	n := len(h.compact.a)
@@ -178,7 +730,7 @@ func (h *Hash[T]) All() []T {
 func (h *Hash[T]) Random() (obj T, ok bool) {
 	n := len(h.compact.a)
 	if n > 0 {
-		return h.compact.a[rand.Intn(n)], true
+		return h.compact.a[h.source().Intn(n)], true
 	}
 	return *new(T), false
 }
@@ -7,6 +7,7 @@ import (
 	"math"
 	"math/rand"
 	"runtime"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -28,14 +29,29 @@ func invariant[T comparable](h *Hash[T], t *testing.T) {
 
 //gocyclo:ignore
 func invariantImpl[T comparable](h *Hash[T]) error {
-	if len(h.loose.a) != len(h.loose.m)+len(h.loose.f) {
-		return fmt.Errorf("len(h.loose.a) != len(h.loose.m) + len(h.loose.f). len(a): %d, len(m): %d, len(f): %d",
-			len(h.loose.a), len(h.loose.m), len(h.loose.f))
+	var numExtra int
+	for _, extra := range h.loose.x {
+		numExtra += len(extra)
+	}
+	if len(h.loose.a) != len(h.loose.m)+numExtra+len(h.loose.f) {
+		return fmt.Errorf("len(h.loose.a) != len(h.loose.m) + numExtra + len(h.loose.f). len(a): %d, len(m): %d, numExtra: %d, len(f): %d",
+			len(h.loose.a), len(h.loose.m), numExtra, len(h.loose.f))
 	}
 	if len(h.compact.a) != len(h.compact.m) {
 		return fmt.Errorf("len(h.compact.a) != len(h.compact.m). len(a): %d, len(m): %d",
 			len(h.compact.a), len(h.compact.m))
 	}
+	if len(h.compact.s) != len(h.compact.a)+numExtra {
+		return fmt.Errorf("len(h.compact.s) != len(h.compact.a) + numExtra. len(s): %d, len(a): %d, numExtra: %d",
+			len(h.compact.s), len(h.compact.a), numExtra)
+	}
+	if len(h.compact.p) != len(h.compact.m) {
+		return fmt.Errorf("len(h.compact.p) != len(h.compact.m). len(p): %d, len(m): %d",
+			len(h.compact.p), len(h.compact.m))
+	}
+	if err := invariantExtra(h); err != nil {
+		return err
+	}
 
 	for obj, idx := range h.loose.m {
 		if opt := h.loose.a[idx]; !opt.b || opt.v != obj {
@@ -69,15 +85,25 @@ func invariantImpl[T comparable](h *Hash[T]) error {
 			return fmt.Errorf("%d should not be in the free list", idx)
 		}
 	}
+	for _, extra := range h.loose.x {
+		for _, idx := range extra {
+			slots[idx] = true
+			usedMap[idx] = struct{}{}
+			m1[idx] = struct{}{}
+			if _, ok := freeMap[idx]; ok {
+				return fmt.Errorf("%d should not be in the free list", idx)
+			}
+		}
+	}
 	for i := range slots {
 		if h.loose.a[i].b != slots[i] {
 			return fmt.Errorf("h.loose.a[i].b != slots[i]. i: %d, b[i]: %v, slots[i]: %v",
 				i, h.loose.a[i].b, slots[i])
 		}
 	}
-	if len(usedMap) != len(h.loose.m) {
-		return fmt.Errorf("len(usedMap) != len(h.loose.m). %d vs %d",
-			len(usedMap), len(h.loose.m))
+	if len(usedMap) != len(h.loose.m)+numExtra {
+		return fmt.Errorf("len(usedMap) != len(h.loose.m) + numExtra. %d vs %d",
+			len(usedMap), len(h.loose.m)+numExtra)
 	}
 	if len(m1) != len(h.loose.a) {
 		return fmt.Errorf("len(m1) != len(h.loose.a). %d vs %d",
@@ -107,6 +133,49 @@ func invariantImpl[T comparable](h *Hash[T]) error {
 	return nil
 }
 
+func invariantExtra[T comparable](h *Hash[T]) error {
+	if len(h.loose.x) != len(h.compact.x) {
+		return fmt.Errorf("len(h.loose.x) != len(h.compact.x). %d vs %d",
+			len(h.loose.x), len(h.compact.x))
+	}
+	for obj, extra := range h.loose.x {
+		if _, ok := h.loose.m[obj]; !ok {
+			return fmt.Errorf("%v has extra slots but it is not in h.loose.m", obj)
+		}
+		if len(extra) == 0 || len(extra) != len(h.compact.x[obj]) {
+			return fmt.Errorf("the numbers of extra slots of %v are wrong. loose: %d, compact: %d",
+				obj, len(extra), len(h.compact.x[obj]))
+		}
+		for _, idx := range extra {
+			if opt := h.loose.a[idx]; !opt.b || opt.v != obj {
+				return fmt.Errorf(`!opt.b || opt.v != obj. obj: %v, idx: %v, opt.b: %v, opt.v: %v`,
+					obj, idx, opt.b, opt.v)
+			}
+		}
+	}
+
+	used := make([]bool, len(h.compact.s))
+	for obj, primary := range h.compact.p {
+		if _, ok := h.compact.m[obj]; !ok {
+			return fmt.Errorf("%v has a compact slot but it is not in h.compact.m", obj)
+		}
+		for _, idx := range append([]int{primary}, h.compact.x[obj]...) {
+			if idx < 0 || idx >= len(used) {
+				return fmt.Errorf("the index of a compact slot is out of range. obj: %v, idx: %d", obj, idx)
+			}
+			if used[idx] {
+				return fmt.Errorf("h.compact.s[%d] is used more than once", idx)
+			}
+			used[idx] = true
+			if h.compact.s[idx] != obj {
+				return fmt.Errorf("h.compact.s[idx] != obj. idx: %d, s[idx]: %v, obj: %v",
+					idx, h.compact.s[idx], obj)
+			}
+		}
+	}
+	return nil
+}
+
 func TestHash_Basic(t *testing.T) {
 	h := NewHash[int]()
 	invariant(h, t)
@@ -484,3 +553,372 @@ func TestHash_Random(t *testing.T) {
 		}
 	}
 }
+
+func checkWeightedBalance(total int, h *Hash[int]) (float64, error) {
+	var totalWeight int
+	for _, obj := range h.All() {
+		totalWeight += h.Weight(obj)
+	}
+	if total < totalWeight*10000 {
+		return 0, errors.New("total is too small")
+	}
+
+	a := make(map[int]int)
+	for i := 0; i < total; i++ {
+		if v, ok := h.Get(uint64(i)); ok {
+			a[v]++
+		} else {
+			panic("impossible")
+		}
+	}
+	if len(a) != h.Len() {
+		return 0, fmt.Errorf("len(a) != h.Len(). len(a): %d, h.Len(): %d", len(a), h.Len())
+	}
+
+	maxErr := float64(0)
+	for obj, c := range a {
+		expected := float64(total) * float64(h.Weight(obj)) / float64(totalWeight)
+		e := math.Abs(float64(c)/expected - 1)
+		maxErr = math.Max(maxErr, e)
+		if e > 0.15 {
+			return 0, fmt.Errorf("not balanced. len: %d, len(f): %d, expected: %.1f, e: %.2f, obj: %d, weight: %d, c: %d",
+				h.Len(), len(h.loose.f), expected, e, obj, h.Weight(obj), c)
+		}
+	}
+
+	return maxErr, nil
+}
+
+func TestHash_AddWeighted(t *testing.T) {
+	h := NewHash[int]()
+	h.AddWeighted(100, 3)
+	h.Add(200)
+	h.AddWeighted(300, 2)
+	h.AddWeighted(100, 5)
+	invariant(h, t)
+
+	if h.Len() != 3 || h.LooseLen() != 6 {
+		t.Fatalf("h.Len() != 3 || h.LooseLen() != 6. len: %d, looseLen: %d", h.Len(), h.LooseLen())
+	}
+	if h.Weight(100) != 3 || h.Weight(200) != 1 || h.Weight(300) != 2 || h.Weight(400) != 0 {
+		t.Fatal("something is wrong with Weight")
+	}
+
+	h.Remove(100)
+	invariant(h, t)
+	if h.Len() != 2 || h.LooseLen() != 6 || len(h.loose.f) != 3 {
+		t.Fatal("something is wrong with Remove")
+	}
+
+	h.AddWeighted(400, 2)
+	invariant(h, t)
+	if h.loose.a[0].v != 400 || h.loose.m[400] != 0 {
+		t.Fatalf("the slots of the removed object should be reused. a: %v", h.loose.a)
+	}
+
+	h.Shrink()
+	invariant(h, t)
+	if h.LooseLen() != 5 {
+		t.Fatalf("h.LooseLen() != 5. looseLen: %d", h.LooseLen())
+	}
+
+	for _, obj := range h.All() {
+		h.Remove(obj)
+		invariant(h, t)
+	}
+	if h.Len() != 0 || len(h.compact.s) != 0 {
+		t.Fatal("h should be empty")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("AddWeighted should panic when weight < 1")
+		}
+	}()
+	h.AddWeighted(500, 0)
+}
+
+func TestHash_SetWeight(t *testing.T) {
+	h := NewHash[int]()
+	for i := 0; i < 10; i++ {
+		h.Add(i)
+	}
+
+	snapshot := func() []int {
+		a := make([]int, 100000)
+		for i := range a {
+			a[i], _ = h.Get(uint64(i))
+		}
+		return a
+	}
+
+	h.SetWeight(100, 3)
+	if h.Len() != 10 || h.Weight(100) != 0 {
+		t.Fatal("SetWeight should do nothing if the object is not in the hash")
+	}
+
+	m0 := snapshot()
+	h.SetWeight(3, 4)
+	invariant(h, t)
+	m1 := snapshot()
+	for i := range m0 {
+		if m0[i] != m1[i] && m1[i] != 3 {
+			t.Fatalf("only the keys moving to 3 should be remapped. key: %d, before: %d, after: %d", i, m0[i], m1[i])
+		}
+	}
+
+	h.SetWeight(3, 2)
+	invariant(h, t)
+	if h.Weight(3) != 2 || len(h.loose.f) != 2 {
+		t.Fatal("something is wrong with SetWeight")
+	}
+	m2 := snapshot()
+	for i := range m1 {
+		if m1[i] != m2[i] && m1[i] != 3 {
+			t.Fatalf("only the keys moving from 3 should be remapped. key: %d, before: %d, after: %d", i, m1[i], m2[i])
+		}
+	}
+
+	h.SetWeight(3, 1)
+	invariant(h, t)
+	if h.Weight(3) != 1 || len(h.loose.x) != 0 || len(h.compact.x) != 0 {
+		t.Fatal("something is wrong with SetWeight")
+	}
+}
+
+func TestHash_WeightedConsistent(t *testing.T) {
+	h := NewHash[int]()
+	for i := 0; i < 20; i++ {
+		h.AddWeighted(i, i%4+1)
+	}
+
+	const total = 100000
+	m0 := make([]int, total)
+	for i := range m0 {
+		m0[i], _ = h.Get(uint64(i))
+	}
+
+	h.Remove(7)
+	invariant(h, t)
+	for i := range m0 {
+		if obj, _ := h.Get(uint64(i)); obj != m0[i] && m0[i] != 7 {
+			t.Fatalf("only the keys owned by 7 should be remapped. key: %d, before: %d, after: %d", i, m0[i], obj)
+		}
+	}
+}
+
+func TestHash_WeightedConsistentWithHoles(t *testing.T) {
+	h := NewHash[int]()
+	for i := 0; i < 40; i++ {
+		h.AddWeighted(i, i%4+1)
+	}
+	for _, obj := range []int{3, 14, 25, 36} {
+		h.Remove(obj)
+	}
+	if len(h.loose.f) == 0 {
+		t.Fatal("the test case is too weak")
+	}
+
+	const total = 100000
+	m0 := make([]int, total)
+	for i := range m0 {
+		m0[i], _ = h.Get(uint64(i))
+	}
+
+	// Only the keys owned by the removed object and the keys of the tail
+	// compact slots, which the removal swaps in, may be remapped.
+	n, w := len(h.compact.s), h.Weight(10)
+	h.Remove(10)
+	invariant(h, t)
+	var moved int
+	for i := range m0 {
+		if obj, _ := h.Get(uint64(i)); obj != m0[i] && m0[i] != 10 {
+			moved++
+		}
+	}
+	if limit := total * w / n; moved > limit {
+		t.Fatalf("too many keys are remapped. moved: %d, limit: %d", moved, limit)
+	}
+}
+
+func TestHash_WeightedBalance(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	h := NewHash[int]()
+	for i := 0; i < 30; i++ {
+		h.AddWeighted(i, r.Intn(5)+1)
+	}
+	invariant(h, t)
+
+	var totalWeight int
+	for _, obj := range h.All() {
+		totalWeight += h.Weight(obj)
+	}
+	if _, err := checkWeightedBalance(totalWeight*10000, h); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		h.Remove(r.Intn(30))
+		h.SetWeight(r.Intn(30), r.Intn(5)+1)
+		invariant(h, t)
+	}
+
+	totalWeight = 0
+	for _, obj := range h.All() {
+		totalWeight += h.Weight(obj)
+	}
+	if _, err := checkWeightedBalance(totalWeight*10000, h); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHash_Replace(t *testing.T) {
+	h := NewHash[int]()
+	for i := 0; i < 20; i++ {
+		h.AddWeighted(i, i%3+1)
+	}
+	h.Remove(4)
+	h.Remove(11)
+	h.Inc(7)
+	h.MarkDown(7)
+	h.Drain(7)
+
+	if h.Replace(100, 200) || h.Replace(7, 8) || h.Replace(7, 7) {
+		t.Fatal("Replace should fail")
+	}
+
+	const total = 100000
+	m0 := make([]int, total)
+	for key := range m0 {
+		m0[key], _ = h.Get(uint64(key))
+	}
+	compact := h.All()
+	weight := h.Weight(7)
+
+	if !h.Replace(7, 100) {
+		t.Fatal("Replace should succeed")
+	}
+	invariant(h, t)
+	if h.Weight(7) != 0 || h.Weight(100) != weight || h.Load(7) != 0 || h.Load(100) != 1 || h.TotalLoad() != 1 ||
+		h.IsDown(7) || h.IsDown(100) || h.IsDraining(100) || len(h.Draining()) != 0 {
+		t.Fatal("something is wrong with Replace")
+	}
+	for i, obj := range h.All() {
+		if compact[i] == 7 && obj != 100 || compact[i] != 7 && obj != compact[i] {
+			t.Fatalf("the compact layout should not change. i: %d, before: %d, after: %d", i, compact[i], obj)
+		}
+	}
+	for key, owner := range m0 {
+		obj, _ := h.Get(uint64(key))
+		if owner == 7 && obj != 100 || owner != 7 && obj != owner {
+			t.Fatalf("no key should move except those of 7. key: %d, before: %d, after: %d", key, owner, obj)
+		}
+	}
+}
+
+func TestHash_AddAt(t *testing.T) {
+	h := NewHash[int]()
+	if err := h.AddAt(1, -1); err != ErrInvalidSlot {
+		t.Fatalf("AddAt should fail with ErrInvalidSlot. err: %v", err)
+	}
+	if err := h.AddAt(1, MaxLooseLen); err != ErrInvalidSlot {
+		t.Fatalf("AddAt should fail with ErrInvalidSlot. err: %v", err)
+	}
+	if err := h.AddAt(1, 3); err != nil {
+		t.Fatal(err)
+	}
+	invariant(h, t)
+	if h.LooseLen() != 4 || h.Len() != 1 || len(h.loose.f) != 3 {
+		t.Fatal("AddAt should grow the loose holder with empty slots")
+	}
+	if err := h.AddAt(1, 5); err != ErrObjectExists {
+		t.Fatalf("AddAt should fail with ErrObjectExists. err: %v", err)
+	}
+	if err := h.AddAt(2, 3); err != ErrSlotOccupied {
+		t.Fatalf("AddAt should fail with ErrSlotOccupied. err: %v", err)
+	}
+	if err := h.AddAt(2, 1); err != nil {
+		t.Fatal(err)
+	}
+	invariant(h, t)
+	h.Add(3)
+	h.Add(4)
+	invariant(h, t)
+
+	if loose, compact, ok := h.SlotOf(2); !ok || loose != 1 || compact != 1 {
+		t.Fatalf("something is wrong with SlotOf. loose: %d, compact: %d, ok: %v", loose, compact, ok)
+	}
+	if _, _, ok := h.SlotOf(100); ok {
+		t.Fatal("SlotOf should fail if the object is not in the hash")
+	}
+
+	slots := h.Slots()
+	if len(slots) != 4 {
+		t.Fatalf("len(slots) != 4. len(slots): %d", len(slots))
+	}
+	for i, slot := range slots {
+		if !slot.Used {
+			t.Fatalf("all slots should be used. i: %d", i)
+		}
+		if loose, _, _ := h.SlotOf(slot.Obj); loose != i {
+			t.Fatalf("something is wrong with Slots. i: %d, obj: %d", i, slot.Obj)
+		}
+	}
+	if NewHash[int]().Slots() != nil {
+		t.Fatal("Slots should return nil when h is empty")
+	}
+
+	if strconv.IntSize > 32 {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("GrowTo should panic when n > MaxLooseLen")
+			}
+		}()
+		n := MaxLooseLen
+		h.GrowTo(n + 1)
+	}
+}
+
+func TestHash_AddAtRebuild(t *testing.T) {
+	h1 := NewHash[int]()
+	for i := 0; i < 50; i++ {
+		h1.Add(i)
+	}
+	for i := 0; i < 50; i += 7 {
+		h1.Remove(i)
+	}
+
+	slots := h1.Slots()
+	type entry struct {
+		obj, loose, compact int
+	}
+	entries := make([]entry, h1.Len())
+	for i, slot := range slots {
+		if slot.Used {
+			loose, compact, _ := h1.SlotOf(slot.Obj)
+			if loose != i {
+				t.Fatalf("loose != i. loose: %d, i: %d", loose, i)
+			}
+			entries[compact] = entry{obj: slot.Obj, loose: loose, compact: compact}
+		}
+	}
+
+	h2 := NewHash[int]()
+	for _, e := range entries {
+		if err := h2.AddAt(e.obj, e.loose); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h2.GrowTo(len(slots))
+	invariant(h2, t)
+	if h2.LooseLen() != h1.LooseLen() {
+		t.Fatal("h2.LooseLen() != h1.LooseLen()")
+	}
+	for key := uint64(0); key < 100000; key++ {
+		v1, _ := h1.Get(key)
+		v2, _ := h2.Get(key)
+		if v1 != v2 {
+			t.Fatalf("h1.Get(%d) != h2.Get(%d). v1: %d, v2: %d", key, key, v1, v2)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package doublejump
+
+// Drain puts an object into the draining state, which is the first step of a
+// graceful decommission. A draining object stays in the hash, so Get keeps
+// returning it for the keys it owns, i.e. the sticky lookup. Meanwhile, GetNew
+// returns the owner the keys will have after the object is removed, which
+// should be used for new assignments. Call FinishDrain to remove the object
+// eventually. Drain does nothing if the object is not in the hash. While any
+// object is draining, every modification of the hash rebuilds the view of
+// GetNew, which costs a copy of the hash.
+func (h *Hash[T]) Drain(obj T) {
+	if _, ok := h.compact.m[obj]; !ok || h.IsDraining(obj) {
+		return
+	}
+	h.draining = append(h.draining, obj)
+	h.redrain()
+}
+
+// IsDraining reports whether an object is in the draining state.
+func (h *Hash[T]) IsDraining(obj T) bool {
+	return contains(h.draining, obj)
+}
+
+// Draining returns the draining objects in the order they were drained.
+func (h *Hash[T]) Draining() []T {
+	if len(h.draining) == 0 {
+		return nil
+	}
+	return append([]T(nil), h.draining...)
+}
+
+// CancelDrain brings a draining object back to the normal state.
+func (h *Hash[T]) CancelDrain(obj T) {
+	if h.undrain(obj) {
+		h.redrain()
+	}
+}
+
+func (h *Hash[T]) undrain(obj T) bool {
+	for i, v := range h.draining {
+		if v == obj {
+			h.draining = append(h.draining[:i], h.draining[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// FinishDrain removes a draining object from the hash and reports whether it
+// succeeded. It does nothing if the object is not in the draining state.
+func (h *Hash[T]) FinishDrain(obj T) bool {
+	if !h.IsDraining(obj) {
+		return false
+	}
+	h.Remove(obj)
+	return true
+}
+
+// GetNew returns the object for the key as if all the draining objects had
+// been removed in the order they were drained, and reports whether it
+// succeeded. It returns the same object as Get if nothing is draining.
+func (h *Hash[T]) GetNew(key uint64) (obj T, ok bool) {
+	if h.drained == nil {
+		return h.Get(key)
+	}
+	return h.drained.Get(key)
+}
+
+// redrain rebuilds the view of GetNew after a change of the hash. The view is
+// built eagerly by the writers, so that GetNew never writes and is safe for
+// concurrent readers.
+func (h *Hash[T]) redrain() {
+	h.drained = nil
+	if len(h.draining) == 0 {
+		return
+	}
+	drained := h.clone()
+	drained.observers = nil
+	drained.draining = nil
+	drained.drained = nil
+	for _, obj := range h.draining {
+		drained.remove(obj)
+	}
+	h.drained = drained
+}
+
+// StickyKeys returns the keys in the given set which are still mapped to the
+// object by Get.
+func (h *Hash[T]) StickyKeys(obj T, keys []uint64) []uint64 {
+	var a []uint64
+	for _, key := range keys {
+		if v, ok := h.Get(key); ok && v == obj {
+			a = append(a, key)
+		}
+	}
+	return a
+}
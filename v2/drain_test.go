@@ -0,0 +1,230 @@
+package doublejump
+
+import (
+	"testing"
+)
+
+func TestHash_Drain(t *testing.T) {
+	h := NewHash[int]()
+	h.Drain(1)
+	if h.IsDraining(1) || h.FinishDrain(1) {
+		t.Fatal("Drain should do nothing if the object is not in the hash")
+	}
+
+	for i := 0; i < 10; i++ {
+		h.AddWeighted(i, i%2+1)
+	}
+	h.Remove(8)
+	h.Drain(3)
+	h.Drain(3)
+	h.Drain(6)
+	if !h.IsDraining(3) || !h.IsDraining(6) || h.IsDraining(5) || len(h.Draining()) != 2 {
+		t.Fatal("something is wrong with Drain")
+	}
+	h.CancelDrain(6)
+	if h.IsDraining(6) || len(h.Draining()) != 1 {
+		t.Fatal("something is wrong with CancelDrain")
+	}
+
+	const total = 100000
+	m0 := make([]int, total)
+	for key := range m0 {
+		m0[key], _ = h.Get(uint64(key))
+	}
+	expected := h.clone()
+	expected.Remove(3)
+
+	m1 := make([]int, total)
+	for key := range m1 {
+		obj, ok := h.GetNew(uint64(key))
+		if !ok || obj == 3 {
+			t.Fatalf("GetNew should not return a draining object. key: %d", key)
+		}
+		if v, _ := expected.Get(uint64(key)); v != obj {
+			t.Fatalf("GetNew should return the owner after removal. key: %d", key)
+		}
+		if v, _ := h.Get(uint64(key)); v != m0[key] {
+			t.Fatalf("Get should not be affected by Drain. key: %d", key)
+		}
+		m1[key] = obj
+	}
+
+	var keys []uint64
+	var n int
+	for key := range m0 {
+		if key%10 == 0 {
+			keys = append(keys, uint64(key))
+			if m0[key] == 3 {
+				n++
+			}
+		}
+	}
+	if sticky := h.StickyKeys(3, keys); len(sticky) != n || n == 0 {
+		t.Fatalf("something is wrong with StickyKeys. len(sticky): %d, n: %d", len(sticky), n)
+	}
+
+	if !h.FinishDrain(3) {
+		t.Fatal("FinishDrain should succeed")
+	}
+	invariant(h, t)
+	if h.Weight(3) != 0 || h.IsDraining(3) || len(h.Draining()) != 0 {
+		t.Fatal("FinishDrain should remove the object")
+	}
+	for key, obj := range m1 {
+		if v, _ := h.Get(uint64(key)); v != obj {
+			t.Fatalf("Get should return what GetNew returned. key: %d", key)
+		}
+		if v, _ := h.GetNew(uint64(key)); v != obj {
+			t.Fatalf("GetNew should be the same as Get. key: %d", key)
+		}
+	}
+	if len(h.StickyKeys(3, keys)) != 0 {
+		t.Fatal("no key should stick to a removed object")
+	}
+}
+
+func TestHash_DrainChurn(t *testing.T) {
+	h := NewHash[int]()
+	for i := 0; i < 20; i++ {
+		h.Add(i)
+	}
+	h.Drain(5)
+	h.Drain(2)
+
+	check := func() {
+		t.Helper()
+		expected := h.clone()
+		for _, obj := range h.Draining() {
+			expected.Remove(obj)
+		}
+		for key := uint64(0); key < 10000; key++ {
+			v1, _ := h.GetNew(key)
+			v2, _ := expected.Get(key)
+			if v1 != v2 {
+				t.Fatalf("GetNew should return the owner after removal. key: %d", key)
+			}
+		}
+	}
+
+	check()
+	h.Add(100)
+	check()
+	h.Remove(7)
+	check()
+	h.SetWeight(9, 3)
+	check()
+	h.Shrink()
+	check()
+	h.Replace(11, 111)
+	check()
+	if err := h.Pin(1, 5); err != nil {
+		t.Fatal(err)
+	}
+	check()
+	h.Unpin(1)
+	check()
+	h.GrowTo(h.LooseLen() + 3)
+	check()
+	if err := h.AddAt(200, h.LooseLen()-1); err != nil {
+		t.Fatal(err)
+	}
+	check()
+	h.AddAll([]int{300, 301})
+	check()
+	h.RemoveAll([]int{300, 13})
+	check()
+	h.ReplaceAll(append(h.All(), 400))
+	check()
+	h.Remove(2)
+	if h.IsDraining(2) || len(h.Draining()) != 1 {
+		t.Fatal("Remove should drop the draining state")
+	}
+	check()
+}
+
+func TestHash_GetNewReadOnly(t *testing.T) {
+	h := NewHash[int]()
+	for i := 0; i < 20; i++ {
+		h.Add(i)
+	}
+	h.Drain(5)
+	drained := h.drained
+	if drained == nil {
+		t.Fatal("Drain should build the view of GetNew")
+	}
+	for key := uint64(0); key < 100; key++ {
+		h.GetNew(key)
+	}
+	if h.drained != drained {
+		t.Fatal("GetNew should not write")
+	}
+	h.CancelDrain(5)
+	if h.drained != nil {
+		t.Fatal("CancelDrain should drop the view of GetNew")
+	}
+}
+
+func TestSyncHash_Drain(t *testing.T) {
+	s := NewSyncHash[int]()
+	for i := 0; i < 10; i++ {
+		s.Add(i)
+	}
+	s.Drain(3)
+	s.Drain(4)
+	s.CancelDrain(4)
+	if !s.IsDraining(3) || s.IsDraining(4) || len(s.Draining()) != 1 {
+		t.Fatal("something is wrong with Drain")
+	}
+
+	var keys []uint64
+	for key := uint64(0); key < 1000; key++ {
+		if obj, _ := s.GetNew(key); obj == 3 {
+			t.Fatalf("GetNew should not return a draining object. key: %d", key)
+		}
+		keys = append(keys, key)
+	}
+	if len(s.StickyKeys(3, keys)) == 0 {
+		t.Fatal("some keys should stick to 3")
+	}
+	if !s.FinishDrain(3) || s.FinishDrain(3) {
+		t.Fatal("something is wrong with FinishDrain")
+	}
+	if s.Weight(3) != 0 || len(s.Draining()) != 0 {
+		t.Fatal("FinishDrain should remove the object")
+	}
+}
+
+func TestAtomicHash_Drain(t *testing.T) {
+	a := NewAtomicHash[int]()
+	for i := 0; i < 10; i++ {
+		a.Add(i)
+	}
+	a.Drain(3)
+	a.Drain(4)
+	snapshot := a.load()
+	a.CancelDrain(4)
+	if !a.IsDraining(3) || a.IsDraining(4) || len(a.Draining()) != 1 {
+		t.Fatal("something is wrong with Drain")
+	}
+	if !snapshot.IsDraining(4) {
+		t.Fatal("CancelDrain should not change the old snapshot")
+	}
+
+	var keys []uint64
+	for key := uint64(0); key < 1000; key++ {
+		if obj, _ := a.GetNew(key); obj == 3 {
+			t.Fatalf("GetNew should not return a draining object. key: %d", key)
+		}
+		keys = append(keys, key)
+	}
+	if len(a.StickyKeys(3, keys)) == 0 {
+		t.Fatal("some keys should stick to 3")
+	}
+	if !a.FinishDrain(3) || a.FinishDrain(3) {
+		t.Fatal("something is wrong with FinishDrain")
+	}
+	if a.Weight(3) != 0 || len(a.Draining()) != 0 {
+		t.Fatal("FinishDrain should remove the object")
+	}
+	invariant(a.load(), t)
+}
@@ -0,0 +1,161 @@
+package doublejump
+
+import (
+	"hash/maphash"
+	"math/bits"
+)
+
+// KeyHasher turns string and byte-slice keys into the uint64 keys accepted by
+// Hash.Get. Implementations must be deterministic and should not allocate.
+type KeyHasher interface {
+	HashString(s string) uint64
+	HashBytes(b []byte) uint64
+}
+
+type fnv1aHasher struct{}
+
+// NewFNV1aHasher returns a KeyHasher implementing the 64-bit FNV-1a algorithm.
+// It is the default KeyHasher of Hash.
+func NewFNV1aHasher() KeyHasher {
+	return fnv1aHasher{}
+}
+
+func (fnv1aHasher) HashString(s string) uint64 {
+	return fnv1a(s)
+}
+
+func (fnv1aHasher) HashBytes(b []byte) uint64 {
+	return fnv1a(b)
+}
+
+func fnv1a[S string | []byte](s S) uint64 {
+	const offset64 = 14695981039346656037
+	const prime64 = 1099511628211
+	h := uint64(offset64)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= prime64
+	}
+	return h
+}
+
+type xxHasher struct {
+	seed uint64
+}
+
+// NewXXHasher returns a KeyHasher implementing the 64-bit xxHash algorithm
+// with the given seed.
+func NewXXHasher(seed uint64) KeyHasher {
+	return xxHasher{seed: seed}
+}
+
+func (x xxHasher) HashString(s string) uint64 {
+	return xxh64(s, x.seed)
+}
+
+func (x xxHasher) HashBytes(b []byte) uint64 {
+	return xxh64(b, x.seed)
+}
+
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMergeRound(acc, val uint64) uint64 {
+	acc ^= xxRound(0, val)
+	return acc*xxPrime1 + xxPrime4
+}
+
+func u64[S string | []byte](s S) uint64 {
+	_ = s[7]
+	return uint64(s[0]) | uint64(s[1])<<8 | uint64(s[2])<<16 | uint64(s[3])<<24 |
+		uint64(s[4])<<32 | uint64(s[5])<<40 | uint64(s[6])<<48 | uint64(s[7])<<56
+}
+
+func u32[S string | []byte](s S) uint64 {
+	_ = s[3]
+	return uint64(s[0]) | uint64(s[1])<<8 | uint64(s[2])<<16 | uint64(s[3])<<24
+}
+
+func xxh64[S string | []byte](s S, seed uint64) uint64 {
+	n := len(s)
+	var h uint64
+	if n >= 32 {
+		v1 := seed + xxPrime1 + xxPrime2
+		v2 := seed + xxPrime2
+		v3 := seed
+		v4 := seed - xxPrime1
+		for len(s) >= 32 {
+			v1 = xxRound(v1, u64(s[0:8]))
+			v2 = xxRound(v2, u64(s[8:16]))
+			v3 = xxRound(v3, u64(s[16:24]))
+			v4 = xxRound(v4, u64(s[24:32]))
+			s = s[32:]
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) +
+			bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		h = xxMergeRound(h, v1)
+		h = xxMergeRound(h, v2)
+		h = xxMergeRound(h, v3)
+		h = xxMergeRound(h, v4)
+	} else {
+		h = seed + xxPrime5
+	}
+
+	h += uint64(n)
+	for ; len(s) >= 8; s = s[8:] {
+		h ^= xxRound(0, u64(s[:8]))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+	}
+	if len(s) >= 4 {
+		h ^= u32(s[:4]) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		s = s[4:]
+	}
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i]) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+	return h
+}
+
+type mapHasher struct {
+	seed maphash.Seed
+}
+
+// NewMapHasher returns a KeyHasher backed by hash/maphash with a fixed seed.
+// Since maphash seeds cannot be persisted, the results are only consistent
+// among the hashers sharing the same seed in the same process.
+func NewMapHasher(seed maphash.Seed) KeyHasher {
+	return mapHasher{seed: seed}
+}
+
+func (m mapHasher) HashString(s string) uint64 {
+	var h maphash.Hash
+	h.SetSeed(m.seed)
+	_, _ = h.WriteString(s)
+	return h.Sum64()
+}
+
+func (m mapHasher) HashBytes(b []byte) uint64 {
+	var h maphash.Hash
+	h.SetSeed(m.seed)
+	_, _ = h.Write(b)
+	return h.Sum64()
+}
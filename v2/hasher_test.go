@@ -0,0 +1,149 @@
+package doublejump
+
+import (
+	"fmt"
+	"hash/fnv"
+	"hash/maphash"
+	"strings"
+	"testing"
+)
+
+var hasherInputs = []string{
+	"",
+	"a",
+	"abc",
+	"hello, world",
+	"Call me Ishmael. Some years ago--never mind how long precisely-",
+	strings.Repeat("doublejump", 10),
+}
+
+func TestFNV1aHasher(t *testing.T) {
+	golden := []uint64{
+		0xcbf29ce484222325,
+		0xaf63dc4c8601ec8c,
+		0xe71fa2190541574b,
+	}
+	hasher := NewFNV1aHasher()
+	for i, s := range hasherInputs {
+		f := fnv.New64a()
+		_, _ = f.Write([]byte(s))
+		expected := f.Sum64()
+		if i < len(golden) && golden[i] != expected {
+			t.Fatalf("golden[%d] is wrong", i)
+		}
+		if v := hasher.HashString(s); v != expected {
+			t.Fatalf("HashString(%q) != 0x%016x. v: 0x%016x", s, expected, v)
+		}
+		if v := hasher.HashBytes([]byte(s)); v != expected {
+			t.Fatalf("HashBytes(%q) != 0x%016x. v: 0x%016x", s, expected, v)
+		}
+	}
+}
+
+func TestXXHasher(t *testing.T) {
+	golden := []uint64{
+		0xef46db3751d8e999,
+		0xd24ec4f1a98c6e5b,
+		0x44bc2cf5ad770999,
+		0xb33a384e6d1b1242,
+		0x02a2e85470d6fd96,
+		0x094910f95c53656c,
+	}
+	hasher := NewXXHasher(0)
+	for i, s := range hasherInputs {
+		if v := hasher.HashString(s); v != golden[i] {
+			t.Fatalf("HashString(%q) != 0x%016x. v: 0x%016x", s, golden[i], v)
+		}
+		if v := hasher.HashBytes([]byte(s)); v != golden[i] {
+			t.Fatalf("HashBytes(%q) != 0x%016x. v: 0x%016x", s, golden[i], v)
+		}
+	}
+	if NewXXHasher(1).HashString("abc") == golden[2] {
+		t.Fatal("the seed should affect the result")
+	}
+}
+
+func TestMapHasher(t *testing.T) {
+	seed := maphash.MakeSeed()
+	h1, h2 := NewMapHasher(seed), NewMapHasher(seed)
+	for _, s := range hasherInputs {
+		if h1.HashString(s) != h2.HashString(s) || h1.HashString(s) != h1.HashBytes([]byte(s)) {
+			t.Fatalf("something is wrong with the map hasher. s: %q", s)
+		}
+	}
+}
+
+func TestHash_GetString(t *testing.T) {
+	golden := map[string][]string{
+		"fnv1a": {"node1", "node2", "node5", "node8", "node7", "node7"},
+		"xx":    {"node7", "node8", "node6", "node6", "node9", "node0"},
+	}
+	hashers := map[string]KeyHasher{
+		"fnv1a": NewFNV1aHasher(),
+		"xx":    NewXXHasher(0),
+	}
+	for name, hasher := range hashers {
+		h := NewHashWithKeyHasher[string](hasher)
+		for i := 0; i < 10; i++ {
+			h.Add(fmt.Sprintf("node%d", i))
+		}
+		for i, s := range hasherInputs {
+			v1, ok1 := h.GetString(s)
+			v2, ok2 := h.GetBytes([]byte(s))
+			if !ok1 || !ok2 || v1 != v2 {
+				t.Fatalf("GetString(%q) != GetBytes(%q). hasher: %s", s, s, name)
+			}
+			if v1 != golden[name][i] {
+				t.Fatalf("GetString(%q) != %s. hasher: %s, v1: %s", s, golden[name][i], name, v1)
+			}
+		}
+	}
+
+	if _, ok := NewHash[string]().GetString("abc"); ok {
+		t.Fatal("ok should be false when h is empty")
+	}
+}
+
+func TestNewWrappersWithKeyHasher(t *testing.T) {
+	hasher := NewXXHasher(7)
+	h := NewHashWithKeyHasher[string](hasher)
+	s := NewSyncHashWithKeyHasher[string](hasher)
+	a := NewAtomicHashWithKeyHasher[string](hasher)
+	for i := 0; i < 20; i++ {
+		node := fmt.Sprintf("node%d", i)
+		h.Add(node)
+		s.Add(node)
+		a.Add(node)
+	}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("user%d", i)
+		v1, _ := h.GetString(key)
+		v2, _ := s.GetString(key)
+		v3, _ := a.GetBytes([]byte(key))
+		if v1 != v2 || v1 != v3 {
+			t.Fatalf("the wrappers should use the KeyHasher. key: %s", key)
+		}
+	}
+}
+
+func TestHash_GetStringAllocs(t *testing.T) {
+	hashers := []KeyHasher{
+		NewFNV1aHasher(),
+		NewXXHasher(0),
+		NewMapHasher(maphash.MakeSeed()),
+	}
+	for _, hasher := range hashers {
+		h := NewHashWithKeyHasher[string](hasher)
+		for i := 0; i < 10; i++ {
+			h.Add(fmt.Sprintf("node%d", i))
+		}
+		key := []byte(hasherInputs[4])
+		allocs := testing.AllocsPerRun(1000, func() {
+			h.GetString(hasherInputs[4])
+			h.GetBytes(key)
+		})
+		if allocs != 0 {
+			t.Fatalf("GetString and GetBytes should not allocate. allocs: %v", allocs)
+		}
+	}
+}
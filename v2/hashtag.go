@@ -0,0 +1,47 @@
+package doublejump
+
+// HashTag returns the hash tag of a key, as Redis Cluster defines it: the part
+// between the first '{' and the first '}' after it. It returns the key itself
+// if there is no such part or the part is empty. For example, the hash tag of
+// "{user42}:cart" is "user42", while that of "{}:cart" is "{}:cart".
+func HashTag(key string) string {
+	return hashTag(key)
+}
+
+func hashTag[S string | []byte](key S) S {
+	for i := 0; i < len(key); i++ {
+		if key[i] != '{' {
+			continue
+		}
+		for j := i + 1; j < len(key); j++ {
+			if key[j] == '}' {
+				if j == i+1 {
+					return key
+				}
+				return key[i+1 : j]
+			}
+		}
+		return key
+	}
+	return key
+}
+
+// SameOwner reports whether all the keys are mapped to the same object by
+// GetString, which makes it possible to validate a multi-key operation before
+// sending it. It returns false if the hash is empty. With hash tags enabled,
+// the keys sharing a hash tag always have the same owner.
+func (h *Hash[T]) SameOwner(keys ...string) bool {
+	if h.Len() == 0 {
+		return false
+	}
+	if len(keys) == 0 {
+		return true
+	}
+	first, _ := h.GetString(keys[0])
+	for _, key := range keys[1:] {
+		if obj, _ := h.GetString(key); obj != first {
+			return false
+		}
+	}
+	return true
+}
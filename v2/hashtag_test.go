@@ -0,0 +1,72 @@
+package doublejump
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHashTag(t *testing.T) {
+	cases := map[string]string{
+		"":                     "",
+		"user42":               "user42",
+		"{user42}:cart":        "user42",
+		"cart:{user42}":        "user42",
+		"{user42}:{cart}":      "user42",
+		"{}:cart":              "{}:cart",
+		"{:cart":               "{:cart",
+		"}{user42}":            "user42",
+		"a{b{c}d}":             "b{c",
+		"{user42}":             "user42",
+		"prefix{}{user42}tail": "prefix{}{user42}tail",
+	}
+	for key, expected := range cases {
+		if v := HashTag(key); v != expected {
+			t.Fatalf("HashTag(%q) != %q. v: %q", key, expected, v)
+		}
+		if v := hashTag([]byte(key)); string(v) != expected {
+			t.Fatalf("hashTag([]byte(%q)) != %q. v: %q", key, expected, v)
+		}
+	}
+}
+
+func TestHash_SameOwner(t *testing.T) {
+	h1 := NewHashWithOptions[string](WithHashTags())
+	h2 := NewHash[string]()
+	if h1.SameOwner("a", "b") || h1.SameOwner() {
+		t.Fatal("SameOwner should return false when h is empty")
+	}
+	for i := 0; i < 100; i++ {
+		h1.Add(fmt.Sprintf("node%d", i))
+		h2.Add(fmt.Sprintf("node%d", i))
+	}
+
+	var different int
+	for i := 0; i < 100; i++ {
+		cart, profile := fmt.Sprintf("{user%d}:cart", i), fmt.Sprintf("{user%d}:profile", i)
+		if !h1.SameOwner(cart, profile, fmt.Sprintf("user%d", i)) {
+			t.Fatalf("the keys with the same hash tag should have the same owner. i: %d", i)
+		}
+		v1, _ := h1.GetString(cart)
+		v2, _ := h2.GetString(fmt.Sprintf("user%d", i))
+		v3, _ := h1.GetBytes([]byte(profile))
+		if v1 != v2 || v1 != v3 {
+			t.Fatalf("only the hash tag should be hashed. i: %d", i)
+		}
+		if !h2.SameOwner(cart, profile) {
+			different++
+		}
+	}
+	if different < 90 {
+		t.Fatalf("the hash tags should be ignored without WithHashTags. different: %d", different)
+	}
+	if !h2.SameOwner("a") || !h2.SameOwner() {
+		t.Fatal("SameOwner should return true for less than two keys")
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		h1.GetString("{user42}:cart")
+	})
+	if allocs != 0 {
+		t.Fatalf("GetString should not allocate. allocs: %v", allocs)
+	}
+}
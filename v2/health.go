@@ -0,0 +1,71 @@
+package doublejump
+
+import (
+	"github.com/dgryski/go-jump"
+)
+
+func cloneSet[T comparable](m map[T]struct{}) map[T]struct{} {
+	if len(m) == 0 {
+		return nil
+	}
+	c := make(map[T]struct{}, len(m))
+	for k := range m {
+		c[k] = struct{}{}
+	}
+	return c
+}
+
+// MarkDown marks an object as unhealthy. Unlike Remove, it keeps the slots of
+// the object, so no key is remapped permanently. It does nothing if the object
+// is not in the hash.
+func (h *Hash[T]) MarkDown(obj T) {
+	if _, ok := h.compact.m[obj]; !ok {
+		return
+	}
+	if h.down == nil {
+		h.down = make(map[T]struct{})
+	}
+	h.down[obj] = struct{}{}
+}
+
+// MarkUp marks an object as healthy again.
+func (h *Hash[T]) MarkUp(obj T) {
+	delete(h.down, obj)
+}
+
+// IsDown reports whether an object is marked as unhealthy.
+func (h *Hash[T]) IsDown(obj T) bool {
+	_, ok := h.down[obj]
+	return ok
+}
+
+// GetHealthy returns the first healthy object for the key and reports whether
+// it succeeded. If the owner of the key is healthy, it returns the same object
+// as Get. Otherwise, it walks the same probe sequence as GetN, so the keys of
+// an unhealthy object fall through to their next replicas deterministically,
+// and go back to the object once it is marked up. The keys owned by healthy
+// objects never move.
+func (h *Hash[T]) GetHealthy(key uint64) (obj T, ok bool) {
+	n := h.Len()
+	if n == 0 || len(h.down) >= n {
+		return obj, false
+	}
+
+	for i, limit := 0, maxProbes(n); i < limit; i++ {
+		if obj, ok = h.probe(key, i); !ok {
+			continue
+		}
+		if _, down := h.down[obj]; !down {
+			return obj, true
+		}
+	}
+
+	offset := int(jump.Hash(key, n))
+	for i := 0; i < n; i++ {
+		obj = h.compact.a[(offset+i)%n]
+		if _, down := h.down[obj]; !down {
+			return obj, true
+		}
+	}
+	return *new(T), false
+}
@@ -0,0 +1,112 @@
+package doublejump
+
+import (
+	"testing"
+)
+
+func TestHash_MarkDown(t *testing.T) {
+	h := NewHash[int]()
+	h.MarkDown(1)
+	if h.IsDown(1) {
+		t.Fatal("MarkDown should do nothing if the object is not in the hash")
+	}
+
+	for i := 0; i < 10; i++ {
+		h.AddWeighted(i, i%2+1)
+	}
+	h.MarkDown(1)
+	h.MarkDown(2)
+	if !h.IsDown(1) || !h.IsDown(2) || h.IsDown(3) {
+		t.Fatal("something is wrong with MarkDown")
+	}
+	h.MarkUp(2)
+	if h.IsDown(2) {
+		t.Fatal("something is wrong with MarkUp")
+	}
+	h.Remove(1)
+	if h.IsDown(1) {
+		t.Fatal("Remove should drop the health state of the object")
+	}
+}
+
+func TestHash_GetHealthy(t *testing.T) {
+	h := NewHash[int]()
+	if _, ok := h.GetHealthy(1); ok {
+		t.Fatal("ok should be false when h is empty")
+	}
+	for i := 0; i < 10; i++ {
+		h.AddWeighted(i, i%2+1)
+	}
+
+	const total = 100000
+	m0 := make([]int, total)
+	for key := range m0 {
+		m0[key], _ = h.Get(uint64(key))
+	}
+
+	h.MarkDown(3)
+	h.MarkDown(6)
+	for key, owner := range m0 {
+		obj, ok := h.GetHealthy(uint64(key))
+		if !ok || obj == 3 || obj == 6 {
+			t.Fatalf("GetHealthy should skip the unhealthy objects. obj: %d", obj)
+		}
+		if owner != 3 && owner != 6 && obj != owner {
+			t.Fatalf("the keys owned by healthy objects should not move. key: %d", key)
+		}
+		if obj2, _ := h.GetHealthy(uint64(key)); obj2 != obj {
+			t.Fatal("GetHealthy should be deterministic")
+		}
+		if owner, _ := h.Get(uint64(key)); owner != m0[key] {
+			t.Fatal("Get should not be affected by MarkDown")
+		}
+	}
+
+	h.MarkUp(3)
+	h.MarkUp(6)
+	for key, owner := range m0 {
+		if obj, _ := h.GetHealthy(uint64(key)); obj != owner {
+			t.Fatalf("the keys should go back after MarkUp. key: %d", key)
+		}
+	}
+
+	for i := 0; i < 9; i++ {
+		h.MarkDown(i)
+	}
+	for key := uint64(0); key < 1000; key++ {
+		if obj, ok := h.GetHealthy(key); !ok || obj != 9 {
+			t.Fatalf("GetHealthy should return the only healthy object. obj: %d", obj)
+		}
+	}
+	h.MarkDown(9)
+	if _, ok := h.GetHealthy(1); ok {
+		t.Fatal("ok should be false when all objects are down")
+	}
+}
+
+func TestHash_GetHealthyWrappers(t *testing.T) {
+	s := NewSyncHash[int]()
+	a := NewAtomicHash[int]()
+	for i := 0; i < 10; i++ {
+		s.Add(i)
+		a.Add(i)
+	}
+	s.MarkDown(4)
+	a.MarkDown(4)
+	old := a.load()
+	if !s.IsDown(4) || !a.IsDown(4) {
+		t.Fatal("something is wrong with MarkDown")
+	}
+	for key := uint64(0); key < 1000; key++ {
+		v1, _ := s.GetHealthy(key)
+		v2, _ := a.GetHealthy(key)
+		if v1 == 4 || v1 != v2 {
+			t.Fatal("something is wrong with GetHealthy")
+		}
+	}
+	s.MarkUp(4)
+	a.MarkUp(4)
+	if s.IsDown(4) || a.IsDown(4) || !old.IsDown(4) {
+		t.Fatal("something is wrong with MarkUp")
+	}
+}
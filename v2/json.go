@@ -0,0 +1,232 @@
+package doublejump
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// jsonVersion is the version of the format written by MarshalJSON.
+const jsonVersion = 1
+
+type jsonHash struct {
+	Version int `json:"version"`
+	// Multiplier is the compact multiplier, encoded as a string like the keys
+	// of the pins. It is omitted if it is DefaultCompactMultiplier.
+	Multiplier uint64 `json:"multiplier,omitempty,string"`
+	// Policy is the free slot policy. It is omitted if it is ReuseLastFreed.
+	Policy  FreeSlotPolicy `json:"policy,omitempty"`
+	Loose   []*string      `json:"loose"`
+	Free    []int          `json:"free"`
+	Compact []string       `json:"compact"`
+	// Primary holds the compact slot of each node in Compact. It is omitted
+	// if every node is in the slot of the same index.
+	Primary []int       `json:"primary,omitempty"`
+	Extra   []jsonExtra `json:"extra,omitempty"`
+	Pins    []jsonPin   `json:"pins,omitempty"`
+}
+
+// jsonExtra records the extra slots of a weighted object in order.
+type jsonExtra struct {
+	Node    string `json:"node"`
+	Loose   []int  `json:"loose"`
+	Compact []int  `json:"compact"`
+}
+
+// jsonPin records a pinned key. The key is encoded as a string, since it may
+// exceed the precision of a JSON number.
+type jsonPin struct {
+	Key  uint64 `json:"key,string"`
+	Node string `json:"node"`
+}
+
+func marshalText[T comparable](obj T) (string, error) {
+	switch v := any(obj).(type) {
+	case string:
+		return v, nil
+	case encoding.TextMarshaler:
+		b, err := v.MarshalText()
+		return string(b), err
+	}
+	if rv := reflect.ValueOf(obj); rv.Kind() == reflect.String {
+		return rv.String(), nil
+	}
+	return "", fmt.Errorf("doublejump: %T is neither a string nor an encoding.TextMarshaler", obj)
+}
+
+func unmarshalText[T comparable](s string) (obj T, err error) {
+	switch p := any(&obj).(type) {
+	case *string:
+		*p = s
+		return obj, nil
+	case encoding.TextUnmarshaler:
+		err = p.UnmarshalText([]byte(s))
+		return obj, err
+	}
+	if rv := reflect.ValueOf(&obj).Elem(); rv.Kind() == reflect.String {
+		rv.SetString(s)
+		return obj, nil
+	}
+	return obj, fmt.Errorf("doublejump: %T is neither a string nor an encoding.TextUnmarshaler", obj)
+}
+
+// MarshalJSON implements the json.Marshaler interface. T must be a string or
+// implement encoding.TextMarshaler. Like MarshalBinary, the result captures
+// the exact layout of the hash, the pins, the compact multiplier and the free
+// slot policy: the loose slots are recorded in order with null for the empty
+// ones.
+func (h *Hash[T]) MarshalJSON() ([]byte, error) {
+	l := h.layout()
+	jh := jsonHash{
+		Version: jsonVersion,
+		Loose:   make([]*string, l.looseLen),
+		Free:    l.free,
+		Compact: make([]string, len(l.objects)),
+		Policy:  l.policy,
+	}
+	if l.mul != DefaultCompactMultiplier {
+		jh.Multiplier = l.mul
+	}
+	if jh.Free == nil {
+		jh.Free = []int{}
+	}
+	var permuted bool
+	primary := make([]int, len(l.objects))
+	for i, o := range l.objects {
+		s, err := marshalText(o.obj)
+		if err != nil {
+			return nil, err
+		}
+		for _, idx := range o.loose {
+			jh.Loose[idx] = &s
+		}
+		jh.Compact[i] = s
+		primary[i] = o.compact[0]
+		permuted = permuted || primary[i] != i
+		if len(o.loose) > 1 {
+			jh.Extra = append(jh.Extra, jsonExtra{
+				Node:    s,
+				Loose:   o.loose[1:],
+				Compact: o.compact[1:],
+			})
+		}
+	}
+	if permuted {
+		jh.Primary = primary
+	}
+	for _, p := range l.pins {
+		jh.Pins = append(jh.Pins, jsonPin{Key: p.key, Node: jh.Compact[p.obj]})
+	}
+	return json.Marshal(jh)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. T must be a string
+// or implement encoding.TextUnmarshaler. It replaces the content of h,
+// including the compact multiplier and the free slot policy, with the layout
+// encoded by MarshalJSON. The loads are reset.
+//
+//gocyclo:ignore
+func (h *Hash[T]) UnmarshalJSON(data []byte) error {
+	var jh jsonHash
+	if err := json.Unmarshal(data, &jh); err != nil {
+		return err
+	}
+	if jh.Version != jsonVersion {
+		return fmt.Errorf("doublejump: unsupported version %d", jh.Version)
+	}
+
+	l := layout[T]{
+		objects:  make([]layoutObject[T], len(jh.Compact)),
+		looseLen: len(jh.Loose),
+		free:     jh.Free,
+		mul:      jh.Multiplier,
+		policy:   jh.Policy,
+	}
+	if l.mul == 0 {
+		l.mul = DefaultCompactMultiplier
+	}
+	indices := make(map[string]int, len(jh.Compact))
+	for i, s := range jh.Compact {
+		if _, ok := indices[s]; ok {
+			return fmt.Errorf("doublejump: duplicate node %q", s)
+		}
+		indices[s] = i
+		obj, err := unmarshalText[T](s)
+		if err != nil {
+			return err
+		}
+		l.objects[i].obj = obj
+	}
+
+	if jh.Primary != nil && len(jh.Primary) != len(jh.Compact) {
+		return errors.New("doublejump: the primary slots are inconsistent")
+	}
+	for i := range l.objects {
+		if jh.Primary != nil {
+			l.objects[i].compact = []int{jh.Primary[i]}
+		} else {
+			l.objects[i].compact = []int{i}
+		}
+	}
+
+	extra := make(map[int]struct{})
+	for _, e := range jh.Extra {
+		i, ok := indices[e.Node]
+		if !ok || len(l.objects[i].loose) > 0 {
+			return fmt.Errorf("doublejump: invalid extra slots of %q", e.Node)
+		}
+		if len(e.Loose) == 0 || len(e.Loose) != len(e.Compact) {
+			return fmt.Errorf("doublejump: the extra slots of %q are inconsistent", e.Node)
+		}
+		// Reserve the first element for the primary slot.
+		l.objects[i].loose = append([]int{-1}, e.Loose...)
+		l.objects[i].compact = append(l.objects[i].compact, e.Compact...)
+		for _, idx := range e.Loose {
+			extra[idx] = struct{}{}
+		}
+	}
+
+	for idx, s := range jh.Loose {
+		if s == nil {
+			continue
+		}
+		i, ok := indices[*s]
+		if !ok {
+			return fmt.Errorf("doublejump: unknown node %q", *s)
+		}
+		if _, ok := extra[idx]; ok {
+			continue
+		}
+		o := &l.objects[i]
+		switch {
+		case len(o.loose) == 0:
+			o.loose = []int{idx}
+		case o.loose[0] < 0:
+			o.loose[0] = idx
+		default:
+			return fmt.Errorf("doublejump: %q occupies too many slots", *s)
+		}
+	}
+	for _, o := range l.objects {
+		if len(o.loose) == 0 || o.loose[0] < 0 {
+			return errors.New("doublejump: some nodes have no slot")
+		}
+	}
+	for _, e := range jh.Extra {
+		for _, idx := range e.Loose {
+			if idx < 0 || idx >= len(jh.Loose) || jh.Loose[idx] == nil || *jh.Loose[idx] != e.Node {
+				return fmt.Errorf("doublejump: invalid extra slot %d of %q", idx, e.Node)
+			}
+		}
+	}
+	for _, p := range jh.Pins {
+		i, ok := indices[p.Node]
+		if !ok {
+			return fmt.Errorf("doublejump: unknown node %q", p.Node)
+		}
+		l.pins = append(l.pins, layoutPin{key: p.Key, obj: i})
+	}
+	return h.restore(l)
+}
@@ -0,0 +1,218 @@
+package doublejump
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestHash_MarshalJSON(t *testing.T) {
+	h1 := NewHash[string]()
+	for i := 0; i < 5; i++ {
+		h1.Add(fmt.Sprintf("node%d", i))
+	}
+	h1.SetWeight("node1", 2)
+	h1.Remove("node2")
+
+	data, err := json.Marshal(h1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = `{"version":1,"loose":["node0","node1",null,"node3","node4","node1"],"free":[2],` +
+		`"compact":["node0","node1","node4","node3"],"primary":[0,1,4,3],` +
+		`"extra":[{"node":"node1","loose":[5],"compact":[2]}]}`
+	if string(data) != expected {
+		t.Fatalf("unexpected json: %s", data)
+	}
+
+	var h2 Hash[string]
+	if err := json.Unmarshal(data, &h2); err != nil {
+		t.Fatal(err)
+	}
+	invariant(&h2, t)
+	if err := checkSameLayout(h1, &h2); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = json.Marshal(NewHash[string]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"version":1,"loose":[],"free":[],"compact":[]}` {
+		t.Fatalf("unexpected json: %s", data)
+	}
+}
+
+func TestHash_MarshalJSONOptions(t *testing.T) {
+	h1 := NewHashWithOptions[string](WithCompactMultiplier(0x9e3779b97f4a7c15), WithFreeSlotPolicy(ReuseLowest))
+	h1.Add("a")
+	h1.Add("b")
+	h1.Add("c")
+	h1.Remove("b")
+
+	data, err := json.Marshal(h1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = `{"version":1,"multiplier":"11400714819323198485","policy":2,` +
+		`"loose":["a",null,"c"],"free":[1],"compact":["a","c"]}`
+	if string(data) != expected {
+		t.Fatalf("unexpected json: %s", data)
+	}
+	h2 := NewHash[string]()
+	if err := json.Unmarshal(data, h2); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSameLayout(h1, h2); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = json.Marshal(NewHash[string]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, h2); err != nil {
+		t.Fatal(err)
+	}
+	if h2.compact.mul != DefaultCompactMultiplier || h2.loose.policy != ReuseLastFreed {
+		t.Fatal("the options should be restored after unmarshalling")
+	}
+}
+
+func TestHash_MarshalJSONChurn(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	h1 := NewHash[string]()
+	for i := 0; i < 300; i++ {
+		obj := fmt.Sprintf("node%d", r.Intn(150))
+		switch r.Intn(4) {
+		case 0:
+			h1.Remove(obj)
+		case 1:
+			h1.SetWeight(obj, r.Intn(3)+1)
+		default:
+			h1.AddWeighted(obj, r.Intn(3)+1)
+		}
+	}
+
+	data, err := json.Marshal(h1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2 := NewHash[string]()
+	if err := json.Unmarshal(data, h2); err != nil {
+		t.Fatal(err)
+	}
+	invariant(h2, t)
+	if err := checkSameLayout(h1, h2); err != nil {
+		t.Fatal(err)
+	}
+
+	h1.Add("new")
+	h2.Add("new")
+	if err := checkSameLayout(h1, h2); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type nodeName string
+
+func TestHash_MarshalJSONTypes(t *testing.T) {
+	h1 := NewHash[netip.Addr]()
+	for i := 1; i <= 5; i++ {
+		h1.Add(netip.AddrFrom4([4]byte{10, 0, 0, byte(i)}))
+	}
+	h1.Remove(netip.AddrFrom4([4]byte{10, 0, 0, 2}))
+	data, err := json.Marshal(h1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"10.0.0.1"`) {
+		t.Fatalf("unexpected json: %s", data)
+	}
+	h2 := NewHash[netip.Addr]()
+	if err := json.Unmarshal(data, h2); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSameLayout(h1, h2); err != nil {
+		t.Fatal(err)
+	}
+
+	h3 := NewHash[nodeName]()
+	h3.Add("a")
+	h3.Add("b")
+	data, err = json.Marshal(h3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h4 := NewHash[nodeName]()
+	if err := json.Unmarshal(data, h4); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSameLayout(h3, h4); err != nil {
+		t.Fatal(err)
+	}
+
+	h5 := NewHash[int]()
+	h5.Add(1)
+	if _, err := json.Marshal(h5); err == nil {
+		t.Fatal("MarshalJSON should fail when T is unsupported")
+	}
+	if err := json.Unmarshal([]byte(`{"version":1,"loose":["1"],"free":[],"compact":["1"]}`), h5); err == nil {
+		t.Fatal("UnmarshalJSON should fail when T is unsupported")
+	}
+}
+
+func TestHash_UnmarshalJSONError(t *testing.T) {
+	cases := []string{
+		`[]`,
+		`{"version":2,"loose":[],"free":[],"compact":[]}`,
+		`{"version":1,"policy":4,"loose":[],"free":[],"compact":[]}`,
+		`{"version":1,"multiplier":1,"loose":[],"free":[],"compact":[]}`,
+		`{"version":1,"loose":["a"],"free":[],"compact":["a","a"]}`,
+		`{"version":1,"loose":["a","b"],"free":[],"compact":["a"]}`,
+		`{"version":1,"loose":["a","a"],"free":[],"compact":["a"]}`,
+		`{"version":1,"loose":["a",null],"free":[],"compact":["a"]}`,
+		`{"version":1,"loose":["a",null],"free":[0],"compact":["a"]}`,
+		`{"version":1,"loose":[null],"free":[0],"compact":["a"]}`,
+		`{"version":1,"loose":["a","a"],"free":[],"compact":["a"],"extra":[{"node":"b","loose":[1],"compact":[0]}]}`,
+		`{"version":1,"loose":["a","a"],"free":[],"compact":["a"],"extra":[{"node":"a","loose":[1],"compact":[]}]}`,
+		`{"version":1,"loose":["a","a"],"free":[],"compact":["a"],"extra":[{"node":"a","loose":[1],"compact":[2]}]}`,
+		`{"version":1,"loose":["a","b"],"free":[],"compact":["a","b"],"extra":[{"node":"a","loose":[1],"compact":[0]}]}`,
+		`{"version":1,"loose":["a","a"],"free":[],"compact":["a"],"extra":[{"node":"a","loose":[0,1],"compact":[0,1]}]}`,
+		`{"version":1,"loose":["a"],"free":[],"compact":["a"],"primary":[0,1]}`,
+		`{"version":1,"loose":["a","a"],"free":[],"compact":["a"],"extra":[{"node":"a","loose":[1],"compact":[0]}]}`,
+		`{"version":1,"loose":["a","a"],"free":[],"compact":["a"],"primary":[2],"extra":[{"node":"a","loose":[1],"compact":[0]}]}`,
+	}
+	for i, c := range cases {
+		h := NewHash[string]()
+		if err := json.Unmarshal([]byte(c), h); err == nil {
+			t.Fatalf("UnmarshalJSON should fail. i: %d, json: %s", i, c)
+		}
+		if h.Len() != 0 {
+			t.Fatalf("h should not change after failures. i: %d", i)
+		}
+	}
+
+	h := NewHash[string]()
+	c := `{"version":1,"loose":["a","a"],"free":[],"compact":["a"],"extra":[{"node":"a","loose":[0],"compact":[1]}]}`
+	if err := json.Unmarshal([]byte(c), h); err != nil {
+		t.Fatal(err)
+	}
+	invariant(h, t)
+	if h.loose.m["a"] != 1 || h.Weight("a") != 2 {
+		t.Fatal("something is wrong with UnmarshalJSON")
+	}
+
+	h = NewHash[string]()
+	c = `{"version":1,"loose":["a","a"],"free":[],"compact":["a"],"primary":[1],"extra":[{"node":"a","loose":[0],"compact":[0]}]}`
+	if err := json.Unmarshal([]byte(c), h); err != nil {
+		t.Fatal(err)
+	}
+	invariant(h, t)
+	if h.compact.p["a"] != 1 || h.Weight("a") != 2 {
+		t.Fatal("something is wrong with UnmarshalJSON")
+	}
+}
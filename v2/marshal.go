@@ -0,0 +1,530 @@
+package doublejump
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// layout is the exact state of the holders of a Hash. Two hashes with the same
+// layout map every key to the same object.
+type layout[T comparable] struct {
+	objects  []layoutObject[T]
+	looseLen int
+	free     []int
+	pins     []layoutPin
+	mul      uint64
+	policy   FreeSlotPolicy
+}
+
+// layoutPin is a pinned key and the index of its object in layout.objects.
+type layoutPin struct {
+	key uint64
+	obj int
+}
+
+// layoutObject records the slots of an object in the inner holders. The
+// primary slots come first.
+type layoutObject[T comparable] struct {
+	obj     T
+	loose   []int
+	compact []int
+}
+
+func (h *Hash[T]) layout() layout[T] {
+	l := layout[T]{
+		objects:  make([]layoutObject[T], len(h.compact.a)),
+		looseLen: len(h.loose.a),
+		free:     append([]int(nil), h.loose.f...),
+		mul:      h.compact.mul,
+		policy:   h.loose.policy,
+	}
+	if l.mul == 0 {
+		l.mul = DefaultCompactMultiplier
+	}
+	for i, obj := range h.compact.a {
+		l.objects[i] = layoutObject[T]{
+			obj:     obj,
+			loose:   append([]int{h.loose.m[obj]}, h.loose.x[obj]...),
+			compact: append([]int{h.compact.p[obj]}, h.compact.x[obj]...),
+		}
+	}
+	for key, obj := range h.pins {
+		l.pins = append(l.pins, layoutPin{key: key, obj: h.compact.m[obj]})
+	}
+	sort.Slice(l.pins, func(i, j int) bool {
+		return l.pins[i].key < l.pins[j].key
+	})
+	return l
+}
+
+//gocyclo:ignore
+func (l *layout[T]) validate() error {
+	if l.mul == 0 {
+		return errors.New("doublejump: invalid compact multiplier")
+	}
+	if l.policy < ReuseLastFreed || l.policy > NoReuse {
+		return fmt.Errorf("doublejump: invalid free slot policy %d", l.policy)
+	}
+
+	numSlots := len(l.free)
+	for _, o := range l.objects {
+		numSlots += len(o.loose)
+	}
+	if l.looseLen != numSlots {
+		return errors.New("doublejump: the loose length is inconsistent")
+	}
+
+	var numCompact int
+	used := make([]bool, l.looseLen)
+	m := make(map[T]struct{}, len(l.objects))
+	for _, o := range l.objects {
+		if _, ok := m[o.obj]; ok {
+			return fmt.Errorf("doublejump: duplicate object %v", o.obj)
+		}
+		m[o.obj] = struct{}{}
+		if len(o.loose) == 0 || len(o.compact) != len(o.loose) {
+			return fmt.Errorf("doublejump: the slots of %v are inconsistent", o.obj)
+		}
+		for _, idx := range o.loose {
+			if idx < 0 || idx >= l.looseLen || used[idx] {
+				return fmt.Errorf("doublejump: invalid loose slot %d", idx)
+			}
+			used[idx] = true
+		}
+		numCompact += len(o.compact)
+	}
+
+	for _, idx := range l.free {
+		if idx < 0 || idx >= l.looseLen || used[idx] {
+			return fmt.Errorf("doublejump: invalid free slot %d", idx)
+		}
+		used[idx] = true
+	}
+
+	compactUsed := make([]bool, numCompact)
+	for _, o := range l.objects {
+		for _, idx := range o.compact {
+			if idx < 0 || idx >= numCompact || compactUsed[idx] {
+				return fmt.Errorf("doublejump: invalid compact slot %d", idx)
+			}
+			compactUsed[idx] = true
+		}
+	}
+
+	pinned := make(map[uint64]struct{}, len(l.pins))
+	for _, p := range l.pins {
+		if _, ok := pinned[p.key]; ok {
+			return fmt.Errorf("doublejump: duplicate pin %d", p.key)
+		}
+		pinned[p.key] = struct{}{}
+		if p.obj < 0 || p.obj >= len(l.objects) {
+			return fmt.Errorf("doublejump: invalid pin %d", p.key)
+		}
+	}
+	return nil
+}
+
+// restore replaces the holders, the pins, the compact multiplier and the free
+// slot policy of h with the layout. The loads
+// are reset, the states of the removed objects are dropped, and the observers
+// are notified of the difference.
+func (h *Hash[T]) restore(l layout[T]) error {
+	if err := l.validate(); err != nil {
+		return err
+	}
+
+	var numCompact int
+	for _, o := range l.objects {
+		numCompact += len(o.compact)
+	}
+
+	loose := looseHolder[T]{
+		a: make([]optional[T], l.looseLen),
+		m: make(map[T]int, len(l.objects)),
+		f: append([]int(nil), l.free...),
+
+		policy: l.policy,
+	}
+	compact := compactHolder[T]{
+		a: make([]T, len(l.objects)),
+		m: make(map[T]int, len(l.objects)),
+		s: make([]T, numCompact),
+		p: make(map[T]int, len(l.objects)),
+
+		mul: l.mul,
+	}
+	if numCompact > len(l.objects) {
+		loose.x = make(map[T][]int)
+		compact.x = make(map[T][]int)
+	}
+	for i, o := range l.objects {
+		for _, idx := range o.loose {
+			loose.a[idx] = optional[T]{v: o.obj, b: true}
+		}
+		loose.m[o.obj] = o.loose[0]
+		compact.a[i] = o.obj
+		compact.m[o.obj] = i
+		for _, idx := range o.compact {
+			compact.s[idx] = o.obj
+		}
+		compact.p[o.obj] = o.compact[0]
+		if len(o.loose) > 1 {
+			loose.x[o.obj] = append([]int(nil), o.loose[1:]...)
+			compact.x[o.obj] = append([]int(nil), o.compact[1:]...)
+		}
+	}
+
+	var pins map[uint64]T
+	if len(l.pins) > 0 {
+		pins = make(map[uint64]T, len(l.pins))
+		for _, p := range l.pins {
+			pins[p.key] = l.objects[p.obj].obj
+		}
+	}
+
+	var c Change[T]
+	if len(h.observers) > 0 {
+		c = h.changeTo(&loose, pins, compact.a)
+	}
+	for _, obj := range h.compact.a {
+		if _, ok := compact.m[obj]; !ok {
+			h.forget(obj)
+		}
+	}
+
+	h.loose = loose
+	h.compact = compact
+	h.pins = pins
+	h.load = loadTracker[T]{factor: h.load.factor}
+	h.redrain()
+	if h.hasher == nil {
+		h.hasher = NewFNV1aHasher()
+	}
+	if !c.empty() {
+		h.notify(c)
+	}
+	return nil
+}
+
+// changeTo returns the change from h to a hash with the given objects, loose
+// holder and pins. The slots of the objects in both hashes are matched in
+// order, and the ones at different indices are reported as relocated.
+func (h *Hash[T]) changeTo(loose *looseHolder[T], pins map[uint64]T, objs []T) Change[T] {
+	var c Change[T]
+	for _, obj := range h.compact.a {
+		if _, ok := loose.m[obj]; !ok {
+			c.Removed = append(c.Removed, obj)
+		}
+	}
+	for _, obj := range objs {
+		if _, ok := h.compact.m[obj]; !ok {
+			c.Added = append(c.Added, obj)
+			continue
+		}
+		from := append([]int{h.loose.m[obj]}, h.loose.x[obj]...)
+		to := append([]int{loose.m[obj]}, loose.x[obj]...)
+		if len(from) != len(to) {
+			c.Reweighted = append(c.Reweighted, obj)
+		}
+		for i := 0; i < len(from) && i < len(to); i++ {
+			if from[i] != to[i] {
+				c.Relocated = append(c.Relocated, Relocation[T]{Obj: obj, From: from[i], To: to[i]})
+			}
+		}
+	}
+
+	for key, obj := range pins {
+		if v, ok := h.pins[key]; !ok || v != obj {
+			c.Pinned = append(c.Pinned, key)
+		}
+	}
+	for key := range h.pins {
+		if _, ok := pins[key]; !ok {
+			c.Unpinned = append(c.Unpinned, key)
+		}
+	}
+	sortKeys(c.Pinned)
+	sortKeys(c.Unpinned)
+	return c
+}
+
+// Codec encodes and decodes the objects of a Hash for MarshalBinary and
+// UnmarshalBinary.
+type Codec[T comparable] interface {
+	Encode(obj T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+type defaultCodec[T comparable] struct{}
+
+// NewDefaultCodec returns a Codec which supports strings, integers and the
+// types implementing both encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler. It is the default Codec of Hash.
+func NewDefaultCodec[T comparable]() Codec[T] {
+	return defaultCodec[T]{}
+}
+
+//gocyclo:ignore
+func (defaultCodec[T]) Encode(obj T) ([]byte, error) {
+	switch v := any(obj).(type) {
+	case string:
+		return []byte(v), nil
+	case int:
+		return appendVarint(nil, int64(v)), nil
+	case int8:
+		return appendVarint(nil, int64(v)), nil
+	case int16:
+		return appendVarint(nil, int64(v)), nil
+	case int32:
+		return appendVarint(nil, int64(v)), nil
+	case int64:
+		return appendVarint(nil, v), nil
+	case uint:
+		return appendUvarint(nil, uint64(v)), nil
+	case uint8:
+		return appendUvarint(nil, uint64(v)), nil
+	case uint16:
+		return appendUvarint(nil, uint64(v)), nil
+	case uint32:
+		return appendUvarint(nil, uint64(v)), nil
+	case uint64:
+		return appendUvarint(nil, v), nil
+	case encoding.BinaryMarshaler:
+		return v.MarshalBinary()
+	default:
+		return nil, fmt.Errorf("doublejump: unsupported object type %T", obj)
+	}
+}
+
+//gocyclo:ignore
+func (defaultCodec[T]) Decode(data []byte) (obj T, err error) {
+	var i int64
+	var u uint64
+	switch p := any(&obj).(type) {
+	case *string:
+		*p = string(data)
+		return obj, nil
+	case *int:
+		i, err = decodeVarint(data, math.MinInt, math.MaxInt)
+		*p = int(i)
+	case *int8:
+		i, err = decodeVarint(data, math.MinInt8, math.MaxInt8)
+		*p = int8(i)
+	case *int16:
+		i, err = decodeVarint(data, math.MinInt16, math.MaxInt16)
+		*p = int16(i)
+	case *int32:
+		i, err = decodeVarint(data, math.MinInt32, math.MaxInt32)
+		*p = int32(i)
+	case *int64:
+		*p, err = decodeVarint(data, math.MinInt64, math.MaxInt64)
+	case *uint:
+		u, err = decodeUvarint(data, math.MaxUint)
+		*p = uint(u)
+	case *uint8:
+		u, err = decodeUvarint(data, math.MaxUint8)
+		*p = uint8(u)
+	case *uint16:
+		u, err = decodeUvarint(data, math.MaxUint16)
+		*p = uint16(u)
+	case *uint32:
+		u, err = decodeUvarint(data, math.MaxUint32)
+		*p = uint32(u)
+	case *uint64:
+		*p, err = decodeUvarint(data, math.MaxUint64)
+	case encoding.BinaryUnmarshaler:
+		err = p.UnmarshalBinary(data)
+	default:
+		err = fmt.Errorf("doublejump: unsupported object type %T", obj)
+	}
+	return obj, err
+}
+
+// appendUvarint is binary.AppendUvarint, which requires Go 1.19.
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+// appendVarint is binary.AppendVarint, which requires Go 1.19.
+func appendVarint(b []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutVarint(buf[:], v)]...)
+}
+
+func decodeVarint(data []byte, min, max int64) (int64, error) {
+	v, n := binary.Varint(data)
+	if n <= 0 || n != len(data) || v < min || v > max {
+		return 0, errors.New("doublejump: invalid integer")
+	}
+	return v, nil
+}
+
+func decodeUvarint(data []byte, max uint64) (uint64, error) {
+	v, n := binary.Uvarint(data)
+	if n <= 0 || n != len(data) || v > max {
+		return 0, errors.New("doublejump: invalid integer")
+	}
+	return v, nil
+}
+
+// SetCodec sets the Codec used by MarshalBinary and UnmarshalBinary.
+func (h *Hash[T]) SetCodec(codec Codec[T]) {
+	h.codec = codec
+}
+
+func (h *Hash[T]) getCodec() Codec[T] {
+	if h.codec == nil {
+		return defaultCodec[T]{}
+	}
+	return h.codec
+}
+
+// binaryVersion is the version of the format written by MarshalBinary.
+const binaryVersion = 1
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface. The result
+// captures the exact layout of the hash, including the empty slots and the
+// order of the free list, so a hash restored from it maps every key to the
+// same object as h does. The objects are encoded with the Codec of h. The
+// pins, the compact multiplier and the free slot policy are included, while the
+// KeyHasher, the Codec and the loads are not.
+func (h *Hash[T]) MarshalBinary() ([]byte, error) {
+	codec := h.getCodec()
+	l := h.layout()
+	data := []byte{binaryVersion}
+	data = appendUvarint(data, l.mul)
+	data = appendUvarint(data, uint64(l.policy))
+	data = appendUvarint(data, uint64(len(l.objects)))
+	for _, o := range l.objects {
+		b, err := codec.Encode(o.obj)
+		if err != nil {
+			return nil, err
+		}
+		data = appendUvarint(data, uint64(len(b)))
+		data = append(data, b...)
+		data = appendUvarint(data, uint64(len(o.loose)))
+		for _, idx := range o.loose {
+			data = appendUvarint(data, uint64(idx))
+		}
+		for _, idx := range o.compact {
+			data = appendUvarint(data, uint64(idx))
+		}
+	}
+	data = appendUvarint(data, uint64(l.looseLen))
+	data = appendUvarint(data, uint64(len(l.free)))
+	for _, idx := range l.free {
+		data = appendUvarint(data, uint64(idx))
+	}
+	data = appendUvarint(data, uint64(len(l.pins)))
+	for _, p := range l.pins {
+		data = appendUvarint(data, p.key)
+		data = appendUvarint(data, uint64(p.obj))
+	}
+	return data, nil
+}
+
+type binaryReader struct {
+	data []byte
+	err  error
+}
+
+func (r *binaryReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = errors.New("doublejump: invalid data")
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+// count reads a number of the following entries, each of which takes at least
+// one byte.
+func (r *binaryReader) count() int {
+	v := r.uvarint()
+	if r.err == nil && v > uint64(len(r.data)) {
+		r.err = errors.New("doublejump: invalid data")
+		return 0
+	}
+	return int(v)
+}
+
+func (r *binaryReader) index() int {
+	v := r.uvarint()
+	if r.err == nil && v > math.MaxInt32 {
+		r.err = errors.New("doublejump: invalid data")
+		return 0
+	}
+	return int(v)
+}
+
+func (r *binaryReader) bytes() []byte {
+	n := r.uvarint()
+	if r.err == nil && n > uint64(len(r.data)) {
+		r.err = errors.New("doublejump: invalid data")
+	}
+	if r.err != nil {
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface. It
+// replaces the content of h, including the compact multiplier and the free slot
+// policy, with the layout encoded by MarshalBinary. The objects are decoded
+// with the Codec of h. The loads are reset.
+func (h *Hash[T]) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != binaryVersion {
+		return errors.New("doublejump: unsupported data version")
+	}
+
+	codec := h.getCodec()
+	r := &binaryReader{data: data[1:]}
+	l := layout[T]{mul: r.uvarint(), policy: FreeSlotPolicy(r.index())}
+	l.objects = make([]layoutObject[T], r.count())
+	for i := range l.objects {
+		b := r.bytes()
+		if r.err != nil {
+			return r.err
+		}
+		obj, err := codec.Decode(b)
+		if err != nil {
+			return err
+		}
+		o := layoutObject[T]{obj: obj, loose: make([]int, r.count())}
+		for j := range o.loose {
+			o.loose[j] = r.index()
+		}
+		o.compact = make([]int, len(o.loose))
+		for j := range o.compact {
+			o.compact[j] = r.index()
+		}
+		l.objects[i] = o
+	}
+	l.looseLen = r.index()
+	l.free = make([]int, r.count())
+	for i := range l.free {
+		l.free[i] = r.index()
+	}
+	l.pins = make([]layoutPin, r.count())
+	for i := range l.pins {
+		l.pins[i] = layoutPin{key: r.uvarint(), obj: r.index()}
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if len(r.data) > 0 {
+		return errors.New("doublejump: invalid data")
+	}
+	return h.restore(l)
+}
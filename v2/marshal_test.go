@@ -0,0 +1,279 @@
+package doublejump
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func churn(h *Hash[int], seed int64) {
+	r := rand.New(rand.NewSource(seed))
+	for i := 0; i < 100; i++ {
+		h.AddWeighted(i, r.Intn(3)+1)
+	}
+	for i := 0; i < 300; i++ {
+		obj := r.Intn(150)
+		switch r.Intn(4) {
+		case 0:
+			h.Remove(obj)
+		case 1:
+			h.SetWeight(obj, r.Intn(3)+1)
+		default:
+			h.Add(obj)
+		}
+	}
+}
+
+func checkSameLayout[T comparable](h1, h2 *Hash[T]) error {
+	if !reflect.DeepEqual(h1.layout(), h2.layout()) {
+		return errors.New("the layouts are different")
+	}
+	for i := 0; i < 10000; i++ {
+		key := uint64(i) * 0x9e3779b97f4a7c15
+		v1, ok1 := h1.Get(key)
+		v2, ok2 := h2.Get(key)
+		if v1 != v2 || ok1 != ok2 {
+			return fmt.Errorf("h1.Get(%d) != h2.Get(%d). v1: %v, v2: %v", key, key, v1, v2)
+		}
+	}
+	return nil
+}
+
+func TestHash_MarshalBinary(t *testing.T) {
+	h1 := NewHash[int]()
+	churn(h1, 1)
+	if len(h1.loose.f) == 0 || len(h1.compact.x) == 0 {
+		t.Fatal("the test case is too weak")
+	}
+
+	data, err := h1.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var h2 Hash[int]
+	if err := h2.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	invariant(&h2, t)
+	if err := checkSameLayout(h1, &h2); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 200; i < 210; i++ {
+		h1.Add(i)
+		h2.Add(i)
+	}
+	h1.SetWeight(200, 3)
+	h2.SetWeight(200, 3)
+	if err := checkSameLayout(h1, &h2); err != nil {
+		t.Fatal(err)
+	}
+
+	h3 := NewHash[int]()
+	if err := h3.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	h4 := NewHash[int]()
+	churn(h4, 1)
+	if err := checkSameLayout(h3, h4); err != nil {
+		t.Fatal(err)
+	}
+
+	empty := NewHash[int]()
+	data, err = empty.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h3.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if h3.Len() != 0 || h3.LooseLen() != 0 {
+		t.Fatal("h3 should be empty")
+	}
+}
+
+func TestHash_MarshalBinaryString(t *testing.T) {
+	h1 := NewHash[string]()
+	for i := 0; i < 10; i++ {
+		h1.Add(fmt.Sprintf("node%d", i))
+	}
+	h1.Remove("node3")
+	h1.Remove("node6")
+	h1.Inc("node1")
+
+	data, err := h1.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2 := NewHash[string]()
+	h2.Add("node100")
+	if err := h2.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	invariant(h2, t)
+	if err := checkSameLayout(h1, h2); err != nil {
+		t.Fatal(err)
+	}
+	if h2.TotalLoad() != 0 {
+		t.Fatal("the loads should be reset")
+	}
+}
+
+type point struct {
+	x, y int32
+}
+
+type pointCodec struct{}
+
+func (pointCodec) Encode(p point) ([]byte, error) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b, uint32(p.x))
+	binary.BigEndian.PutUint32(b[4:], uint32(p.y))
+	return b, nil
+}
+
+func (pointCodec) Decode(data []byte) (point, error) {
+	if len(data) != 8 {
+		return point{}, errors.New("invalid point")
+	}
+	x := binary.BigEndian.Uint32(data)
+	y := binary.BigEndian.Uint32(data[4:])
+	return point{x: int32(x), y: int32(y)}, nil
+}
+
+func TestHash_Codec(t *testing.T) {
+	h1 := NewHash[point]()
+	if _, err := h1.MarshalBinary(); err != nil {
+		t.Fatal("an empty hash should always be marshalable")
+	}
+	h1.Add(point{1, 2})
+	if _, err := h1.MarshalBinary(); err == nil {
+		t.Fatal("MarshalBinary should fail without a proper codec")
+	}
+
+	h1.SetCodec(pointCodec{})
+	for i := int32(0); i < 10; i++ {
+		h1.AddWeighted(point{i, -i}, int(i%3)+1)
+	}
+	h1.Remove(point{4, -4})
+	data, err := h1.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h2 := NewHash[point]()
+	if err := h2.UnmarshalBinary(data); err == nil {
+		t.Fatal("UnmarshalBinary should fail without a proper codec")
+	}
+	h2.SetCodec(pointCodec{})
+	if err := h2.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	invariant(h2, t)
+	if err := checkSameLayout(h1, h2); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefaultCodec(t *testing.T) {
+	check := func(v1 any, v2 any, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v1 != v2 {
+			t.Fatalf("v1 != v2. v1: %v, v2: %v", v1, v2)
+		}
+	}
+
+	s, err := NewDefaultCodec[string]().Decode([]byte("abc"))
+	check("abc", s, err)
+	b, _ := NewDefaultCodec[int8]().Encode(-100)
+	i8, err := NewDefaultCodec[int8]().Decode(b)
+	check(int8(-100), i8, err)
+	b, _ = NewDefaultCodec[uint64]().Encode(1 << 63)
+	u64, err := NewDefaultCodec[uint64]().Decode(b)
+	check(uint64(1<<63), u64, err)
+
+	b, _ = NewDefaultCodec[int]().Encode(1000)
+	if _, err := NewDefaultCodec[int8]().Decode(b); err == nil {
+		t.Fatal("Decode should fail when the value overflows")
+	}
+	if _, err := NewDefaultCodec[uint16]().Decode(nil); err == nil {
+		t.Fatal("Decode should fail when data is empty")
+	}
+	if _, err := NewDefaultCodec[float64]().Encode(1); err == nil {
+		t.Fatal("Encode should fail when the type is unsupported")
+	}
+}
+
+func TestHash_UnmarshalBinaryError(t *testing.T) {
+	h1 := NewHash[int]()
+	for i := 0; i < 10; i++ {
+		h1.AddWeighted(i, 2)
+	}
+	h1.Remove(5)
+	data, err := h1.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h2 := NewHash[int]()
+	if err := h2.UnmarshalBinary(nil); err == nil {
+		t.Fatal("UnmarshalBinary should fail when data is empty")
+	}
+	if err := h2.UnmarshalBinary([]byte{100}); err == nil {
+		t.Fatal("UnmarshalBinary should fail when the version is unsupported")
+	}
+	for i := 1; i < len(data); i++ {
+		if err := h2.UnmarshalBinary(data[:i]); err == nil {
+			t.Fatalf("UnmarshalBinary should fail when data is truncated. i: %d", i)
+		}
+	}
+	if err := h2.UnmarshalBinary(append(data, 0)); err == nil {
+		t.Fatal("UnmarshalBinary should fail when data has trailing bytes")
+	}
+
+	l := h1.layout()
+	l.objects[1].loose[0] = l.objects[0].loose[0]
+	if err := h2.restore(l); err == nil {
+		t.Fatal("restore should fail when a slot is used twice")
+	}
+	l = h1.layout()
+	l.free = l.free[1:]
+	if err := h2.restore(l); err == nil {
+		t.Fatal("restore should fail when the free list is incomplete")
+	}
+	l = h1.layout()
+	l.objects[2].compact[1] = l.objects[3].compact[1]
+	if err := h2.restore(l); err == nil {
+		t.Fatal("restore should fail when a compact slot is used twice")
+	}
+	l = h1.layout()
+	l.objects[2].compact = l.objects[2].compact[:1]
+	if err := h2.restore(l); err == nil {
+		t.Fatal("restore should fail when the compact slots are incomplete")
+	}
+	l = h1.layout()
+	l.mul = 0
+	if err := h2.restore(l); err == nil {
+		t.Fatal("restore should fail when the compact multiplier is 0")
+	}
+	l = h1.layout()
+	l.policy = NoReuse + 1
+	if err := h2.restore(l); err == nil {
+		t.Fatal("restore should fail when the free slot policy is invalid")
+	}
+	l = h1.layout()
+	l.objects[3].obj = l.objects[4].obj
+	if err := h2.restore(l); err == nil {
+		t.Fatal("restore should fail when an object is duplicated")
+	}
+
+	if h2.Len() != 0 {
+		t.Fatal("h2 should not change after failures")
+	}
+}
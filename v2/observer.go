@@ -0,0 +1,46 @@
+package doublejump
+
+// Relocation describes a slot moved by Shrink or by unmarshaling.
+type Relocation[T comparable] struct {
+	Obj  T
+	From int
+	To   int
+}
+
+// Change describes a modification of a Hash.
+type Change[T comparable] struct {
+	// Added holds the objects added to the hash.
+	Added []T
+	// Removed holds the objects removed from the hash.
+	Removed []T
+	// Reweighted holds the objects whose weights have changed.
+	Reweighted []T
+	// Relocated holds the slots moved by Shrink or by unmarshaling. The keys
+	// of these slots may be remapped although the objects stay in the hash.
+	Relocated []Relocation[T]
+	// Grown is the number of empty slots appended by GrowTo or AddAt. The
+	// keys of these slots may be remapped.
+	Grown int
+	// Pinned holds the keys pinned by Pin or by unmarshaling.
+	Pinned []uint64
+	// Unpinned holds the keys unpinned by Unpin, by unmarshaling, or by the
+	// removal of the objects they were pinned to.
+	Unpinned []uint64
+}
+
+// OnChange registers fn to be called after every modification of the hash,
+// in the same goroutine. fn must not modify the hash.
+func (h *Hash[T]) OnChange(fn func(Change[T])) {
+	h.observers = append(h.observers, fn)
+}
+
+func (c *Change[T]) empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Reweighted) == 0 &&
+		len(c.Relocated) == 0 && c.Grown == 0 && len(c.Pinned) == 0 && len(c.Unpinned) == 0
+}
+
+func (h *Hash[T]) notify(c Change[T]) {
+	for _, fn := range h.observers {
+		fn(c)
+	}
+}
@@ -0,0 +1,159 @@
+package doublejump
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestHash_OnChange(t *testing.T) {
+	var changes []Change[string]
+	h := NewHash[string]()
+	h.OnChange(func(c Change[string]) {
+		changes = append(changes, c)
+	})
+	expect := func(c Change[string]) {
+		t.Helper()
+		if len(changes) != 1 || !reflect.DeepEqual(changes[0], c) {
+			t.Fatalf("unexpected changes: %+v", changes)
+		}
+		changes = nil
+	}
+	expectNothing := func() {
+		t.Helper()
+		if len(changes) != 0 {
+			t.Fatalf("unexpected changes: %+v", changes)
+		}
+	}
+
+	h.Add("a")
+	expect(Change[string]{Added: []string{"a"}})
+	h.Add("a")
+	expectNothing()
+	h.AddWeighted("b", 2)
+	expect(Change[string]{Added: []string{"b"}})
+	h.Add("c")
+	h.Add("d")
+	changes = nil
+
+	h.SetWeight("b", 3)
+	expect(Change[string]{Reweighted: []string{"b"}})
+	h.SetWeight("b", 3)
+	h.SetWeight("x", 3)
+	expectNothing()
+
+	h.Remove("a")
+	expect(Change[string]{Removed: []string{"a"}})
+	h.Remove("a")
+	expectNothing()
+
+	// b: [1], c: [3], d: [4], free: [0, 5, 2]
+	h.SetWeight("b", 1)
+	changes = nil
+	h.Shrink()
+	expect(Change[string]{Relocated: []Relocation[string]{
+		{Obj: "b", From: 1, To: 0},
+		{Obj: "c", From: 3, To: 1},
+		{Obj: "d", From: 4, To: 2},
+	}})
+	h.Shrink()
+	expectNothing()
+
+	h.Replace("c", "e")
+	expect(Change[string]{Added: []string{"e"}, Removed: []string{"c"}})
+	h.Replace("c", "f")
+	expectNothing()
+
+	// b: [0], e: [1], d: [2]
+	h.GrowTo(5)
+	expect(Change[string]{Grown: 2})
+	h.GrowTo(5)
+	expectNothing()
+	if err := h.AddAt("f", 7); err != nil {
+		t.Fatal(err)
+	}
+	expect(Change[string]{Added: []string{"f"}, Grown: 2})
+	if err := h.AddAt("g", 3); err != nil {
+		t.Fatal(err)
+	}
+	expect(Change[string]{Added: []string{"g"}})
+}
+
+func TestHash_OnChangeUnmarshal(t *testing.T) {
+	h1 := NewHash[string]()
+	h1.Add("a")
+	h1.AddWeighted("b", 2)
+	_ = h1.Pin(1, "a")
+	_ = h1.Pin(2, "b")
+	data, err := json.Marshal(h1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h2 := NewHash[string]()
+	h2.Add("b")
+	h2.Add("c")
+	_ = h2.Pin(2, "c")
+	_ = h2.Pin(3, "b")
+	var changes []Change[string]
+	h2.OnChange(func(c Change[string]) {
+		changes = append(changes, c)
+	})
+	if err := json.Unmarshal(data, h2); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Change[string]{{
+		Added:      []string{"a"},
+		Removed:    []string{"c"},
+		Reweighted: []string{"b"},
+		Relocated:  []Relocation[string]{{Obj: "b", From: 0, To: 1}},
+		Pinned:     []uint64{1, 2},
+		Unpinned:   []uint64{3},
+	}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+
+	changes = nil
+	if err := json.Unmarshal(data, h2); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("unmarshaling the same layout should change nothing. changes: %+v", changes)
+	}
+}
+
+func TestHash_OnChangeWrappers(t *testing.T) {
+	var n1, n2 int
+	s := NewSyncHash[int]()
+	s.OnChange(func(c Change[int]) {
+		n1 += len(c.Added) + len(c.Removed)
+	})
+	a := NewAtomicHash[int]()
+	a.OnChange(func(c Change[int]) {
+		n2 += len(c.Added) + len(c.Removed)
+	})
+	for i := 0; i < 10; i++ {
+		s.Add(i)
+		a.Add(i)
+	}
+	s.Remove(3)
+	a.Remove(3)
+	if n1 != 11 || n2 != 11 {
+		t.Fatalf("n1 != 11 || n2 != 11. n1: %d, n2: %d", n1, n2)
+	}
+}
+
+func TestHash_AddAllocs(t *testing.T) {
+	h := NewHash[int]()
+	for i := 0; i < 100; i++ {
+		h.Add(i)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		h.Remove(50)
+		h.Add(50)
+	})
+	if allocs != 0 {
+		t.Fatalf("Add and Remove should not allocate without observers. allocs: %v", allocs)
+	}
+}
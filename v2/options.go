@@ -0,0 +1,99 @@
+package doublejump
+
+// DefaultCompactMultiplier is the default constant used by the inner compact
+// object holder to mix the keys.
+const DefaultCompactMultiplier = 0xc6a4a7935bd1e995
+
+// FreeSlotPolicy decides which empty slot of the inner loose object holder is
+// reused when adding an object.
+type FreeSlotPolicy int
+
+const (
+	// ReuseLastFreed reuses the most recently freed slot. It is the default.
+	ReuseLastFreed FreeSlotPolicy = iota
+	// ReuseFirstFreed reuses the least recently freed slot.
+	ReuseFirstFreed
+	// ReuseLowest reuses the empty slot with the lowest index.
+	ReuseLowest
+	// NoReuse never reuses empty slots. New objects are always appended, and
+	// the empty slots are only removed by Shrink.
+	NoReuse
+)
+
+type options struct {
+	capacity   int
+	hasher     KeyHasher
+	multiplier uint64
+	rnd        Intner
+	policy     FreeSlotPolicy
+	hashTags   bool
+}
+
+func defaultOptions() options {
+	return options{
+		hasher:     NewFNV1aHasher(),
+		multiplier: DefaultCompactMultiplier,
+		policy:     ReuseLastFreed,
+	}
+}
+
+// Option configures a Hash created by NewHashWithOptions.
+type Option func(o *options)
+
+// WithCapacity presizes the inner holders for n objects.
+func WithCapacity(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.capacity = n
+		}
+	}
+}
+
+// WithKeyHasher sets the KeyHasher used by GetString and GetBytes. The default
+// is NewFNV1aHasher().
+func WithKeyHasher(hasher KeyHasher) Option {
+	return func(o *options) {
+		if hasher != nil {
+			o.hasher = hasher
+		}
+	}
+}
+
+// WithCompactMultiplier sets the constant used by the inner compact object
+// holder to mix the keys. It should be a large odd number. The default is
+// DefaultCompactMultiplier. Hashes with different constants map the keys of
+// their empty slots differently.
+func WithCompactMultiplier(mul uint64) Option {
+	return func(o *options) {
+		if mul != 0 {
+			o.multiplier = mul
+		}
+	}
+}
+
+// WithRand sets the source of randomness used by Random, RandomN, RandomExcept
+// and RandomWeighted. The default is the global source of math/rand. Note that
+// a rand.Rand is not thread-safe; SyncHash and AtomicHash serialize the calls
+// to r themselves.
+func WithRand(r Intner) Option {
+	return func(o *options) {
+		o.rnd = r
+	}
+}
+
+// WithFreeSlotPolicy sets the policy of reusing empty slots. The default is
+// ReuseLastFreed.
+func WithFreeSlotPolicy(policy FreeSlotPolicy) Option {
+	return func(o *options) {
+		o.policy = policy
+	}
+}
+
+// WithHashTags makes GetString and GetBytes hash only the hash tag of a key,
+// if it has one, so that the keys sharing a hash tag, e.g. "{user42}:cart" and
+// "{user42}:profile", are mapped to the same object. See HashTag.
+func WithHashTags() Option {
+	return func(o *options) {
+		o.hashTags = true
+	}
+}
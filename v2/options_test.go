@@ -0,0 +1,185 @@
+package doublejump
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestNewHashWithOptions(t *testing.T) {
+	h1 := NewHash[int]()
+	h2 := NewHashWithOptions[int]()
+	h3 := NewHashWithOptions[int](WithCapacity(100), WithKeyHasher(NewFNV1aHasher()),
+		WithCompactMultiplier(DefaultCompactMultiplier), WithFreeSlotPolicy(ReuseLastFreed))
+	if cap(h3.loose.a) != 100 || cap(h3.compact.a) != 100 {
+		t.Fatal("WithCapacity should presize the holders")
+	}
+	for _, h := range []*Hash[int]{h1, h2, h3} {
+		for i := 0; i < 100; i++ {
+			h.Add(i)
+		}
+		for i := 0; i < 100; i += 3 {
+			h.Remove(i)
+		}
+		h.Add(1000)
+		invariant(h, t)
+	}
+	if err := checkSameLayout(h1, h2); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSameLayout(h1, h3); err != nil {
+		t.Fatal(err)
+	}
+	v1, _ := h1.GetString("abc")
+	v3, _ := h3.GetString("abc")
+	if v1 != v3 {
+		t.Fatal("the default KeyHasher should be FNV-1a")
+	}
+}
+
+func TestWithCompactMultiplier(t *testing.T) {
+	h1 := NewHash[int]()
+	h2 := NewHashWithOptions[int](WithCompactMultiplier(0x9e3779b97f4a7c15))
+	for _, h := range []*Hash[int]{h1, h2} {
+		for i := 0; i < 10; i++ {
+			h.Add(i)
+		}
+		h.Remove(3)
+	}
+
+	var diff int
+	for key := uint64(0); key < 10000; key++ {
+		v1, _ := h1.Get(key)
+		v2, _ := h2.Get(key)
+		if v1 != v2 {
+			diff++
+			if _, ok := h1.loose.get(key); ok {
+				t.Fatal("the multiplier should only affect the keys of the empty slots")
+			}
+		}
+	}
+	if diff == 0 {
+		t.Fatal("the multiplier should affect the keys of the empty slots")
+	}
+
+	data, err := h2.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h3 := NewHash[int]()
+	if err := h3.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSameLayout(h2, h3); err != nil {
+		t.Fatal(err)
+	}
+	var h4 Hash[int]
+	if err := h4.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSameLayout(h2, &h4); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = h1.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h2.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if h2.compact.mul != DefaultCompactMultiplier {
+		t.Fatal("the multiplier should be restored after unmarshalling")
+	}
+}
+
+func TestWithRand(t *testing.T) {
+	var a [2][]int
+	for i := range a {
+		h := NewHashWithOptions[int](WithRand(rand.New(rand.NewSource(1))))
+		for j := 0; j < 100; j++ {
+			h.Add(j)
+		}
+		for j := 0; j < 100; j++ {
+			v, _ := h.Random()
+			a[i] = append(a[i], v)
+		}
+	}
+	for i := range a[0] {
+		if a[0][i] != a[1][i] {
+			t.Fatal("Random should be reproducible with the same source")
+		}
+	}
+}
+
+func TestWithFreeSlotPolicy(t *testing.T) {
+	cases := []struct {
+		policy   FreeSlotPolicy
+		expected []int
+	}{
+		{ReuseLastFreed, []int{3, 1, 5}},
+		{ReuseFirstFreed, []int{5, 1, 3}},
+		{ReuseLowest, []int{1, 3, 5}},
+		{NoReuse, []int{10, 11, 12}},
+	}
+	for _, c := range cases {
+		h := NewHashWithOptions[int](WithFreeSlotPolicy(c.policy))
+		for i := 0; i < 10; i++ {
+			h.Add(i)
+		}
+		h.Remove(5)
+		h.Remove(1)
+		h.Remove(3)
+
+		data, err := h.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		h2 := NewHash[int]()
+		if err := h2.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, h := range []*Hash[int]{h, h2} {
+			for i, expected := range c.expected {
+				h.Add(100 + i)
+				invariant(h, t)
+				if loose, _, _ := h.SlotOf(100 + i); loose != expected {
+					t.Fatalf("unexpected slot. policy: %d, i: %d, slot: %d, expected: %d", c.policy, i, loose, expected)
+				}
+			}
+			h.Shrink()
+			invariant(h, t)
+			if h.LooseLen() != 10 {
+				t.Fatalf("h.LooseLen() != 10. policy: %d", c.policy)
+			}
+		}
+	}
+}
+
+func TestNewWrappersWithOptions(t *testing.T) {
+	hasher := NewXXHasher(7)
+	h := NewHashWithOptions[string](WithKeyHasher(hasher))
+	s := NewSyncHashWithOptions[string](WithKeyHasher(hasher), WithHashTags())
+	a := NewAtomicHashWithOptions[string](WithKeyHasher(hasher), WithHashTags())
+	for i := 0; i < 20; i++ {
+		node := fmt.Sprintf("node%d", i)
+		h.Add(node)
+		s.Add(node)
+		a.Add(node)
+	}
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("user%d", i)
+		v1, _ := h.GetString(key)
+		v2, _ := s.GetString("{" + key + "}:cart")
+		v3, _ := a.GetBytes([]byte("{" + key + "}:profile"))
+		if v1 != v2 || v1 != v3 {
+			t.Fatalf("the wrappers should use the options. key: %s", key)
+		}
+		cart, profile := "{"+key+"}:cart", "{"+key+"}:profile"
+		if !s.SameOwner(cart, profile) || !a.SameOwner(cart, profile) {
+			t.Fatalf("the wrappers should respect the hash tags. key: %s", key)
+		}
+	}
+}
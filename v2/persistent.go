@@ -0,0 +1,304 @@
+package doublejump
+
+import (
+	"math/bits"
+)
+
+const (
+	trieBits  = 5
+	trieWidth = 1 << trieBits
+	trieMask  = trieWidth - 1
+)
+
+// pvec is a persistent vector, i.e. a trie of 32-way nodes whose leaves hold
+// the elements. Every modification copies the path to the element and shares
+// the rest of the trie with the original. The nodes are never modified after
+// creation.
+type pvec[E any] struct {
+	root  *pvecNode[E]
+	size  int
+	shift uint
+}
+
+type pvecNode[E any] struct {
+	kids []*pvecNode[E]
+	vals []E
+}
+
+func (v pvec[E]) get(i int) E {
+	n := v.root
+	for s := v.shift; s > 0; s -= trieBits {
+		n = n.kids[(i>>s)&trieMask]
+	}
+	return n.vals[i&trieMask]
+}
+
+func (v pvec[E]) set(i int, e E) pvec[E] {
+	v.root = v.root.set(v.shift, i, e)
+	return v
+}
+
+func (n *pvecNode[E]) set(shift uint, i int, e E) *pvecNode[E] {
+	if shift == 0 {
+		c := &pvecNode[E]{vals: append([]E(nil), n.vals...)}
+		c.vals[i&trieMask] = e
+		return c
+	}
+	c := &pvecNode[E]{kids: append([]*pvecNode[E](nil), n.kids...)}
+	j := (i >> shift) & trieMask
+	c.kids[j] = n.kids[j].set(shift-trieBits, i, e)
+	return c
+}
+
+func (v pvec[E]) push(e E) pvec[E] {
+	switch {
+	case v.size == 0:
+		v.root = &pvecNode[E]{vals: []E{e}}
+	case v.size == 1<<(v.shift+trieBits):
+		v.root = &pvecNode[E]{kids: []*pvecNode[E]{v.root, newPvecPath(v.shift, e)}}
+		v.shift += trieBits
+	default:
+		v.root = v.root.push(v.shift, v.size, e)
+	}
+	v.size++
+	return v
+}
+
+func newPvecPath[E any](shift uint, e E) *pvecNode[E] {
+	if shift == 0 {
+		return &pvecNode[E]{vals: []E{e}}
+	}
+	return &pvecNode[E]{kids: []*pvecNode[E]{newPvecPath(shift-trieBits, e)}}
+}
+
+func (n *pvecNode[E]) push(shift uint, i int, e E) *pvecNode[E] {
+	if shift == 0 {
+		vals := make([]E, len(n.vals), len(n.vals)+1)
+		copy(vals, n.vals)
+		return &pvecNode[E]{vals: append(vals, e)}
+	}
+	kids := make([]*pvecNode[E], len(n.kids), len(n.kids)+1)
+	copy(kids, n.kids)
+	if j := (i >> shift) & trieMask; j < len(kids) {
+		kids[j] = kids[j].push(shift-trieBits, i, e)
+	} else {
+		kids = append(kids, newPvecPath(shift-trieBits, e))
+	}
+	return &pvecNode[E]{kids: kids}
+}
+
+func (v pvec[E]) pop() pvec[E] {
+	if v.size == 1 {
+		return pvec[E]{}
+	}
+	v.size--
+	v.root = v.root.pop(v.shift, v.size)
+	if v.shift > 0 && len(v.root.kids) == 1 {
+		v.root = v.root.kids[0]
+		v.shift -= trieBits
+	}
+	return v
+}
+
+// pop removes the element at index i, which must be the last one, and
+// returns nil if the node becomes empty.
+func (n *pvecNode[E]) pop(shift uint, i int) *pvecNode[E] {
+	if shift == 0 {
+		if len(n.vals) == 1 {
+			return nil
+		}
+		return &pvecNode[E]{vals: append([]E(nil), n.vals[:len(n.vals)-1]...)}
+	}
+	j := (i >> shift) & trieMask
+	kid := n.kids[j].pop(shift-trieBits, i)
+	if kid == nil && j == 0 {
+		return nil
+	}
+	if kid == nil {
+		return &pvecNode[E]{kids: append([]*pvecNode[E](nil), n.kids[:j]...)}
+	}
+	c := &pvecNode[E]{kids: append([]*pvecNode[E](nil), n.kids...)}
+	c.kids[j] = kid
+	return c
+}
+
+// hamt is a persistent hash array mapped trie. Each level consumes 5 bits of
+// the hash of a key; the keys whose hashes are identical end up in a
+// collision node below the last level. Like pvec, every modification copies
+// the path to the entry and shares the rest of the trie.
+type hamt[K comparable, V any] struct {
+	root *hamtNode[K, V]
+	size int
+}
+
+type hamtNode[K comparable, V any] struct {
+	bitmap  uint32
+	entries []hamtEntry[K, V]
+}
+
+// hamtEntry is either a child node or a key-value pair.
+type hamtEntry[K comparable, V any] struct {
+	child *hamtNode[K, V]
+	hash  uint64
+	key   K
+	val   V
+}
+
+// isCollision reports whether a node at the shift is a collision node, whose
+// entries are unordered key-value pairs with the same hash.
+func isCollision(shift uint) bool {
+	return shift >= 64
+}
+
+func (m hamt[K, V]) get(hash uint64, key K) (val V, ok bool) {
+	n := m.root
+	for shift := uint(0); n != nil; shift += trieBits {
+		if isCollision(shift) {
+			for _, e := range n.entries {
+				if e.key == key {
+					return e.val, true
+				}
+			}
+			return val, false
+		}
+		bit := uint32(1) << ((hash >> shift) & trieMask)
+		if n.bitmap&bit == 0 {
+			return val, false
+		}
+		e := &n.entries[bits.OnesCount32(n.bitmap&(bit-1))]
+		if e.child == nil {
+			if e.hash == hash && e.key == key {
+				return e.val, true
+			}
+			return val, false
+		}
+		n = e.child
+	}
+	return val, false
+}
+
+func (m hamt[K, V]) set(hash uint64, key K, val V) hamt[K, V] {
+	var added bool
+	m.root, added = m.root.set(0, hamtEntry[K, V]{hash: hash, key: key, val: val})
+	if added {
+		m.size++
+	}
+	return m
+}
+
+func (n *hamtNode[K, V]) set(shift uint, kv hamtEntry[K, V]) (*hamtNode[K, V], bool) {
+	if n == nil {
+		if isCollision(shift) {
+			return &hamtNode[K, V]{entries: []hamtEntry[K, V]{kv}}, true
+		}
+		return &hamtNode[K, V]{bitmap: uint32(1) << ((kv.hash >> shift) & trieMask), entries: []hamtEntry[K, V]{kv}}, true
+	}
+
+	if isCollision(shift) {
+		for i, e := range n.entries {
+			if e.key == kv.key {
+				c := n.copy()
+				c.entries[i] = kv
+				return c, false
+			}
+		}
+		return &hamtNode[K, V]{entries: append(n.copy().entries, kv)}, true
+	}
+
+	bit := uint32(1) << ((kv.hash >> shift) & trieMask)
+	pos := bits.OnesCount32(n.bitmap & (bit - 1))
+	if n.bitmap&bit == 0 {
+		entries := make([]hamtEntry[K, V], 0, len(n.entries)+1)
+		entries = append(entries, n.entries[:pos]...)
+		entries = append(entries, kv)
+		entries = append(entries, n.entries[pos:]...)
+		return &hamtNode[K, V]{bitmap: n.bitmap | bit, entries: entries}, true
+	}
+
+	c := n.copy()
+	e := n.entries[pos]
+	switch {
+	case e.child != nil:
+		child, added := e.child.set(shift+trieBits, kv)
+		c.entries[pos] = hamtEntry[K, V]{child: child}
+		return c, added
+	case e.key == kv.key:
+		c.entries[pos] = kv
+		return c, false
+	default:
+		child, _ := (*hamtNode[K, V])(nil).set(shift+trieBits, e)
+		child, _ = child.set(shift+trieBits, kv)
+		c.entries[pos] = hamtEntry[K, V]{child: child}
+		return c, true
+	}
+}
+
+func (m hamt[K, V]) delete(hash uint64, key K) hamt[K, V] {
+	root, removed := m.root.delete(0, hash, key)
+	if removed {
+		m.root = root
+		m.size--
+	}
+	return m
+}
+
+// delete returns nil if the node becomes empty.
+func (n *hamtNode[K, V]) delete(shift uint, hash uint64, key K) (*hamtNode[K, V], bool) {
+	if n == nil {
+		return nil, false
+	}
+
+	if isCollision(shift) {
+		for i, e := range n.entries {
+			if e.key == key {
+				return n.without(i, 0), true
+			}
+		}
+		return n, false
+	}
+
+	bit := uint32(1) << ((hash >> shift) & trieMask)
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+	pos := bits.OnesCount32(n.bitmap & (bit - 1))
+	e := n.entries[pos]
+	if e.child == nil {
+		if e.hash != hash || e.key != key {
+			return n, false
+		}
+		return n.without(pos, bit), true
+	}
+
+	child, removed := e.child.delete(shift+trieBits, hash, key)
+	if !removed {
+		return n, false
+	}
+	if child == nil {
+		return n.without(pos, bit), true
+	}
+	c := n.copy()
+	if len(child.entries) == 1 && child.entries[0].child == nil {
+		// Pull the last key-value pair of the child up to keep the trie shallow.
+		c.entries[pos] = child.entries[0]
+	} else {
+		c.entries[pos] = hamtEntry[K, V]{child: child}
+	}
+	return c, true
+}
+
+func (n *hamtNode[K, V]) copy() *hamtNode[K, V] {
+	return &hamtNode[K, V]{bitmap: n.bitmap, entries: append([]hamtEntry[K, V](nil), n.entries...)}
+}
+
+// without returns a copy of n without the i-th entry, or nil if the copy
+// would be empty.
+func (n *hamtNode[K, V]) without(i int, bit uint32) *hamtNode[K, V] {
+	if len(n.entries) == 1 {
+		return nil
+	}
+	entries := make([]hamtEntry[K, V], 0, len(n.entries)-1)
+	entries = append(entries, n.entries[:i]...)
+	entries = append(entries, n.entries[i+1:]...)
+	return &hamtNode[K, V]{bitmap: n.bitmap &^ bit, entries: entries}
+}
@@ -0,0 +1,136 @@
+package doublejump
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestPvec(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var v pvec[int]
+	var expected []int
+	type version struct {
+		v pvec[int]
+		a []int
+	}
+	var versions []version
+	for i := 0; i < 20000; i++ {
+		switch n := len(expected); {
+		case n > 0 && r.Intn(4) == 0:
+			v = v.pop()
+			expected = expected[:n-1]
+		case n > 0 && r.Intn(3) == 0:
+			j, x := r.Intn(n), r.Int()
+			v = v.set(j, x)
+			expected[j] = x
+		default:
+			x := r.Int()
+			v = v.push(x)
+			expected = append(expected, x)
+		}
+		if i%500 == 0 {
+			versions = append(versions, version{v: v, a: append([]int(nil), expected...)})
+		}
+	}
+	versions = append(versions, version{v: v, a: expected})
+	if len(expected) < 2000 {
+		t.Fatal("the test case is too weak")
+	}
+
+	for _, ver := range versions {
+		if ver.v.size != len(ver.a) {
+			t.Fatalf("ver.v.size != len(ver.a). size: %d, len: %d", ver.v.size, len(ver.a))
+		}
+		for i, x := range ver.a {
+			if y := ver.v.get(i); x != y {
+				t.Fatalf("v.get(%d) != %d. y: %d", i, x, y)
+			}
+		}
+	}
+
+	for v.size > 0 {
+		v = v.pop()
+	}
+	if v.root != nil || v.shift != 0 {
+		t.Fatal("the vector should be empty")
+	}
+}
+
+func TestHamt(t *testing.T) {
+	hashes := map[string]func(int) uint64{
+		"good": func(k int) uint64 {
+			return mix64(uint64(k))
+		},
+		"bad": func(k int) uint64 {
+			return uint64(k % 7)
+		},
+		"constant": func(k int) uint64 {
+			return 42
+		},
+	}
+	for name, hash := range hashes {
+		r := rand.New(rand.NewSource(1))
+		var m hamt[int, int]
+		expected := make(map[int]int)
+		type version struct {
+			m hamt[int, int]
+			a map[int]int
+		}
+		var versions []version
+		for i := 0; i < 5000; i++ {
+			k := r.Intn(300)
+			if r.Intn(3) == 0 {
+				m = m.delete(hash(k), k)
+				delete(expected, k)
+			} else {
+				x := r.Int()
+				m = m.set(hash(k), k, x)
+				expected[k] = x
+			}
+			if i%250 == 0 {
+				a := make(map[int]int, len(expected))
+				for k, v := range expected {
+					a[k] = v
+				}
+				versions = append(versions, version{m: m, a: a})
+			}
+		}
+		versions = append(versions, version{m: m, a: expected})
+
+		for _, ver := range versions {
+			if ver.m.size != len(ver.a) {
+				t.Fatalf("ver.m.size != len(ver.a). hash: %s, size: %d, len: %d", name, ver.m.size, len(ver.a))
+			}
+			for k := 0; k < 300; k++ {
+				x, ok1 := ver.a[k]
+				y, ok2 := ver.m.get(hash(k), k)
+				if ok1 != ok2 || x != y {
+					t.Fatalf("m.get(%d) is wrong. hash: %s", k, name)
+				}
+			}
+		}
+
+		for k := 0; k < 300; k++ {
+			m = m.delete(hash(k), k)
+		}
+		if m.root != nil || m.size != 0 {
+			t.Fatalf("the map should be empty. hash: %s", name)
+		}
+	}
+}
+
+func TestHamt_Shape(t *testing.T) {
+	hash := func(k int) uint64 {
+		return uint64(k) << 60
+	}
+	var m hamt[int, int]
+	m = m.set(hash(1), 1, 1)
+	m = m.set(hash(17), 17, 17)
+	m = m.delete(hash(17), 17)
+	var expected hamt[int, int]
+	expected = expected.set(hash(1), 1, 1)
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatal("the trie should shrink back after deletions")
+	}
+}
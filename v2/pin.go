@@ -0,0 +1,96 @@
+package doublejump
+
+import (
+	"sort"
+)
+
+func clonePins[T comparable](pins map[uint64]T) map[uint64]T {
+	if len(pins) == 0 {
+		return nil
+	}
+	c := make(map[uint64]T, len(pins))
+	for k, v := range pins {
+		c[k] = v
+	}
+	return c
+}
+
+// Pin overrides the hash for the key: Get and the other lookups return obj for
+// the key until it is unpinned, no matter how the hash changes. A pin is
+// dropped automatically when its object is removed from the hash. Pin fails
+// if the object is not in the hash.
+func (h *Hash[T]) Pin(key uint64, obj T) error {
+	if _, ok := h.compact.m[obj]; !ok {
+		return ErrObjectNotFound
+	}
+	if v, ok := h.pins[key]; ok && v == obj {
+		return nil
+	}
+
+	if h.pins == nil {
+		h.pins = make(map[uint64]T)
+	}
+	h.pins[key] = obj
+	h.redrain()
+	if len(h.observers) > 0 {
+		h.notify(Change[T]{Pinned: []uint64{key}})
+	}
+	return nil
+}
+
+// Unpin removes the pin of the key and reports whether the key was pinned.
+func (h *Hash[T]) Unpin(key uint64) bool {
+	if _, ok := h.pins[key]; !ok {
+		return false
+	}
+
+	delete(h.pins, key)
+	h.redrain()
+	if len(h.observers) > 0 {
+		h.notify(Change[T]{Unpinned: []uint64{key}})
+	}
+	return true
+}
+
+// Pinned returns the object the key is pinned to, and reports whether the key
+// is pinned.
+func (h *Hash[T]) Pinned(key uint64) (obj T, ok bool) {
+	obj, ok = h.pins[key]
+	return
+}
+
+// unpinObject drops the pins of an object and returns their keys in ascending
+// order.
+func (h *Hash[T]) unpinObject(obj T) []uint64 {
+	var keys []uint64
+	for key, v := range h.pins {
+		if v == obj {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys {
+		delete(h.pins, key)
+	}
+	sortKeys(keys)
+	return keys
+}
+
+// movePins moves the pins of an object to another one and returns their keys
+// in ascending order.
+func (h *Hash[T]) movePins(from, to T) []uint64 {
+	var keys []uint64
+	for key, v := range h.pins {
+		if v == from {
+			h.pins[key] = to
+			keys = append(keys, key)
+		}
+	}
+	sortKeys(keys)
+	return keys
+}
+
+func sortKeys(keys []uint64) {
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+}
@@ -0,0 +1,184 @@
+package doublejump
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestHash_Pin(t *testing.T) {
+	h := NewHash[string]()
+	for _, obj := range []string{"a", "b", "c", "d", "e"} {
+		h.Add(obj)
+	}
+	var changes []Change[string]
+	h.OnChange(func(c Change[string]) {
+		changes = append(changes, c)
+	})
+	expect := func(c Change[string]) {
+		t.Helper()
+		if len(changes) != 1 || !reflect.DeepEqual(changes[0], c) {
+			t.Fatalf("unexpected changes: %+v", changes)
+		}
+		changes = nil
+	}
+
+	if err := h.Pin(1, "x"); err != ErrObjectNotFound {
+		t.Fatal("Pin should fail when the object does not exist")
+	}
+	var key uint64
+	for obj, _ := h.Get(key); obj == "e"; obj, _ = h.Get(key) {
+		key++
+	}
+	if err := h.Pin(key, "e"); err != nil {
+		t.Fatal(err)
+	}
+	expect(Change[string]{Pinned: []uint64{key}})
+	_ = h.Pin(key, "e")
+	if len(changes) != 0 {
+		t.Fatal("pinning a key to the same object again should be a no-op")
+	}
+	if obj, ok := h.Pinned(key); !ok || obj != "e" {
+		t.Fatal("something is wrong with Pinned")
+	}
+	if obj, _ := h.Get(key); obj != "e" {
+		t.Fatal("Get should return the pinned object")
+	}
+	if a := h.GetN(key, 2); a[0] != "e" {
+		t.Fatal("GetN should start with the pinned object")
+	}
+	out := make([]string, 1)
+	if h.GetBatch([]uint64{key}, out, nil); out[0] != "e" {
+		t.Fatal("GetBatch should return the pinned object")
+	}
+
+	if !h.Unpin(key) || h.Unpin(key) {
+		t.Fatal("something is wrong with Unpin")
+	}
+	expect(Change[string]{Unpinned: []uint64{key}})
+	if obj, _ := h.Get(key); obj == "e" {
+		t.Fatal("Get should not return the unpinned object")
+	}
+
+	_ = h.Pin(30, "e")
+	_ = h.Pin(10, "e")
+	_ = h.Pin(20, "d")
+	changes = nil
+	h.Remove("e")
+	expect(Change[string]{Removed: []string{"e"}, Unpinned: []uint64{10, 30}})
+	if _, ok := h.Pinned(10); ok {
+		t.Fatal("the pins of a removed object should be dropped")
+	}
+	if obj, _ := h.Pinned(20); obj != "d" {
+		t.Fatal("the other pins should stay")
+	}
+
+	h.Replace("d", "f")
+	expect(Change[string]{Added: []string{"f"}, Removed: []string{"d"}, Pinned: []uint64{20}})
+	if obj, _ := h.Pinned(20); obj != "f" {
+		t.Fatal("Replace should move the pins to the new object")
+	}
+	_ = h.Pin(40, "a")
+	_ = h.Pin(50, "b")
+	changes = nil
+	h.RemoveAll([]string{"a", "b"})
+	expect(Change[string]{Removed: []string{"a", "b"}, Unpinned: []uint64{40, 50}})
+	invariant(h, t)
+}
+
+func TestHash_PinDrain(t *testing.T) {
+	h := NewHash[int]()
+	for i := 0; i < 10; i++ {
+		h.Add(i)
+	}
+	_ = h.Pin(100, 3)
+	_ = h.Pin(200, 4)
+	h.Drain(3)
+	if obj, _ := h.Get(100); obj != 3 {
+		t.Fatal("Get should keep returning the draining object")
+	}
+	if obj, _ := h.GetNew(100); obj == 3 {
+		t.Fatal("GetNew should not return the draining object")
+	}
+	if obj, _ := h.GetNew(200); obj != 4 {
+		t.Fatal("GetNew should respect the pins")
+	}
+	h.FinishDrain(3)
+	if _, ok := h.Pinned(100); ok {
+		t.Fatal("FinishDrain should drop the pins")
+	}
+}
+
+func TestHash_MarshalPins(t *testing.T) {
+	h1 := NewHash[string]()
+	for _, obj := range []string{"a", "b", "c"} {
+		h1.Add(obj)
+	}
+	_ = h1.Pin(1<<63+5, "c")
+	_ = h1.Pin(7, "a")
+
+	data, err := h1.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2 := NewHash[string]()
+	if err := h2.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSameLayout(h1, h2); err != nil {
+		t.Fatal(err)
+	}
+	if obj, _ := h2.Get(1<<63 + 5); obj != "c" {
+		t.Fatal("the pins should survive MarshalBinary")
+	}
+
+	data, err = json.Marshal(h1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = `{"version":1,"loose":["a","b","c"],"free":[],"compact":["a","b","c"],` +
+		`"pins":[{"key":"7","node":"a"},{"key":"9223372036854775813","node":"c"}]}`
+	if string(data) != expected {
+		t.Fatalf("unexpected json: %s", data)
+	}
+	h3 := NewHash[string]()
+	if err := json.Unmarshal(data, h3); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSameLayout(h1, h3); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []string{
+		`{"version":1,"loose":["a"],"free":[],"compact":["a"],"pins":[{"key":"1","node":"b"}]}`,
+		`{"version":1,"loose":["a"],"free":[],"compact":["a"],"pins":[{"key":"1","node":"a"},{"key":"1","node":"a"}]}`,
+	}
+	for i, c := range cases {
+		if err := json.Unmarshal([]byte(c), NewHash[string]()); err == nil {
+			t.Fatalf("UnmarshalJSON should fail. i: %d, json: %s", i, c)
+		}
+	}
+}
+
+func TestAtomicHash_Pin(t *testing.T) {
+	a := NewAtomicHash[int]()
+	for i := 0; i < 10; i++ {
+		a.Add(i)
+	}
+	if err := a.Pin(1, 100); err != ErrObjectNotFound {
+		t.Fatal("Pin should fail when the object does not exist")
+	}
+	if err := a.Pin(1, 7); err != nil {
+		t.Fatal(err)
+	}
+	if obj, _ := a.Get(1); obj != 7 {
+		t.Fatal("Get should return the pinned object")
+	}
+	a.Add(10)
+	if obj, _ := a.Pinned(1); obj != 7 {
+		t.Fatal("the pins should survive the copies")
+	}
+	if !a.Unpin(1) || a.Unpin(1) {
+		t.Fatal("something is wrong with Unpin")
+	}
+}
@@ -0,0 +1,152 @@
+package doublejump
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// Intner is a source of random numbers, e.g. a *rand.Rand. Intn returns a
+// number in [0, n). The package supports Go 1.18, so a Rand of math/rand/v2,
+// whose method is named IntN, needs a one-line adapter.
+type Intner interface {
+	Intn(n int) int
+}
+
+type globalRand struct{}
+
+func (globalRand) Intn(n int) int {
+	return rand.Intn(n)
+}
+
+// SetRand sets the source of randomness used by Random, RandomN, RandomExcept
+// and RandomWeighted. A nil r means the global source of math/rand. Note that
+// a rand.Rand is not thread-safe, so it must not be shared by the goroutines
+// calling these methods concurrently.
+func (h *Hash[T]) SetRand(r Intner) {
+	h.rnd = r
+}
+
+// lockedRand serializes the calls to a source shared by the readers of
+// SyncHash and AtomicHash.
+type lockedRand struct {
+	mu sync.Mutex
+	r  Intner
+}
+
+func (l *lockedRand) Intn(n int) int {
+	l.mu.Lock()
+	v := l.r.Intn(n)
+	l.mu.Unlock()
+	return v
+}
+
+// lockRand makes the source of h safe for concurrent use.
+func (h *Hash[T]) lockRand() {
+	if _, ok := h.rnd.(*lockedRand); h.rnd != nil && !ok {
+		h.rnd = &lockedRand{r: h.rnd}
+	}
+}
+
+func (h *Hash[T]) source() Intner {
+	if h.rnd != nil {
+		return h.rnd
+	}
+	return globalRand{}
+}
+
+// RandomFrom returns a random object chosen with r and reports whether it
+// succeeded.
+func (h *Hash[T]) RandomFrom(r Intner) (obj T, ok bool) {
+	n := len(h.compact.a)
+	if n > 0 {
+		return h.compact.a[r.Intn(n)], true
+	}
+	return *new(T), false
+}
+
+// RandomN returns n distinct random objects. It returns all the objects in a
+// random order if n >= h.Len().
+func (h *Hash[T]) RandomN(n int) []T {
+	return h.RandomNFrom(h.source(), n)
+}
+
+// RandomNFrom is like RandomN but chooses the objects with r.
+func (h *Hash[T]) RandomNFrom(r Intner, n int) []T {
+	all := h.All()
+	if n > len(all) {
+		n = len(all)
+	}
+	if n <= 0 {
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		j := i + r.Intn(len(all)-i)
+		all[i], all[j] = all[j], all[i]
+	}
+	return all[:n]
+}
+
+// RandomExcept returns a random object other than the excluded ones and
+// reports whether it succeeded. It is useful for retrying on a different
+// object.
+func (h *Hash[T]) RandomExcept(exclude ...T) (obj T, ok bool) {
+	return h.RandomExceptFrom(h.source(), exclude...)
+}
+
+// RandomExceptFrom is like RandomExcept but chooses the object with r.
+func (h *Hash[T]) RandomExceptFrom(r Intner, exclude ...T) (obj T, ok bool) {
+	n := len(h.compact.a)
+	if len(exclude) == 0 {
+		return h.RandomFrom(r)
+	}
+
+	const maxTries = 8
+	for i := 0; i < maxTries && n > 0; i++ {
+		obj = h.compact.a[r.Intn(n)]
+		if !contains(exclude, obj) {
+			return obj, true
+		}
+	}
+
+	var numExcluded int
+	for i, v := range exclude {
+		if _, ok := h.compact.m[v]; ok && !contains(exclude[:i], v) {
+			numExcluded++
+		}
+	}
+	if numExcluded >= n {
+		return *new(T), false
+	}
+	k := r.Intn(n - numExcluded)
+	for _, obj = range h.compact.a {
+		if contains(exclude, obj) {
+			continue
+		}
+		if k == 0 {
+			break
+		}
+		k--
+	}
+	return obj, true
+}
+
+// RandomWeighted returns a random object with a probability proportional to
+// its weight and reports whether it succeeded. It picks one of the compact
+// slots uniformly, which takes O(1) and needs no extra bookkeeping since every
+// object occupies exactly weight compact slots.
+func (h *Hash[T]) RandomWeighted() (obj T, ok bool) {
+	n := len(h.compact.s)
+	if n == 0 {
+		return *new(T), false
+	}
+	return h.compact.s[h.source().Intn(n)], true
+}
+
+// RandomWeightedFrom is like RandomWeighted but chooses the object with r.
+func (h *Hash[T]) RandomWeightedFrom(r Intner) (obj T, ok bool) {
+	n := len(h.compact.s)
+	if n == 0 {
+		return *new(T), false
+	}
+	return h.compact.s[r.Intn(n)], true
+}
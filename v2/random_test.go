@@ -0,0 +1,231 @@
+package doublejump
+
+import (
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestHash_RandomFrom(t *testing.T) {
+	h := NewHash[int]()
+	if _, ok := h.RandomFrom(rand.New(rand.NewSource(1))); ok {
+		t.Fatal("ok should be false when h is empty")
+	}
+	for i := 0; i < 100; i++ {
+		h.Add(i)
+	}
+
+	r1, r2 := rand.New(rand.NewSource(1)), rand.New(rand.NewSource(1))
+	h.SetRand(r2)
+	for i := 0; i < 1000; i++ {
+		v1, ok1 := h.RandomFrom(r1)
+		v2, ok2 := h.Random()
+		if !ok1 || !ok2 || v1 != v2 {
+			t.Fatal("RandomFrom and Random should agree with the same source")
+		}
+	}
+	h.SetRand(nil)
+	if _, ok := h.Random(); !ok {
+		t.Fatal("Random should fall back to the global source")
+	}
+}
+
+func TestHash_RandomN(t *testing.T) {
+	h := NewHash[int]()
+	if a := h.RandomN(3); a != nil {
+		t.Fatal("RandomN should return nil when h is empty")
+	}
+	for i := 0; i < 20; i++ {
+		h.Add(i)
+	}
+	h.SetRand(rand.New(rand.NewSource(1)))
+
+	counts := make([]int, 20)
+	for i := 0; i < 10000; i++ {
+		a := h.RandomN(5)
+		if len(a) != 5 {
+			t.Fatalf("len(a) != 5. len(a): %d", len(a))
+		}
+		for j, v := range a {
+			if contains(a[:j], v) {
+				t.Fatalf("the objects returned by RandomN should be distinct. a: %v", a)
+			}
+			counts[v]++
+		}
+	}
+	for v, c := range counts {
+		if c < 2000 || c > 3000 {
+			t.Fatalf("RandomN is not uniform. v: %d, c: %d", v, c)
+		}
+	}
+
+	if a := h.RandomN(100); len(a) != 20 {
+		t.Fatalf("RandomN should return all objects when n > h.Len(). len(a): %d", len(a))
+	}
+	if a := h.RandomN(0); a != nil {
+		t.Fatal("RandomN should return nil when n is 0")
+	}
+}
+
+func TestHash_RandomExcept(t *testing.T) {
+	h := NewHash[int]()
+	if _, ok := h.RandomExcept(1); ok {
+		t.Fatal("ok should be false when h is empty")
+	}
+	for i := 0; i < 10; i++ {
+		h.Add(i)
+	}
+	h.SetRand(rand.New(rand.NewSource(1)))
+
+	if _, ok := h.RandomExcept(); !ok {
+		t.Fatal("RandomExcept should succeed without exclusion")
+	}
+	counts := make([]int, 10)
+	for i := 0; i < 10000; i++ {
+		v, ok := h.RandomExcept(3, 5, 5, 100)
+		if !ok || v == 3 || v == 5 {
+			t.Fatalf("RandomExcept should not return the excluded objects. v: %d", v)
+		}
+		counts[v]++
+	}
+	for v, c := range counts {
+		if v != 3 && v != 5 && (c < 1000 || c > 1500) {
+			t.Fatalf("RandomExcept is not uniform. v: %d, c: %d", v, c)
+		}
+	}
+
+	for i := 0; i < 1000; i++ {
+		if v, ok := h.RandomExcept(0, 1, 2, 3, 4, 5, 6, 8, 9); !ok || v != 7 {
+			t.Fatalf("RandomExcept should return the only candidate. v: %d", v)
+		}
+	}
+	if _, ok := h.RandomExcept(0, 1, 2, 3, 4, 5, 6, 7, 8, 9); ok {
+		t.Fatal("ok should be false when all objects are excluded")
+	}
+}
+
+func TestHash_RandomWeighted(t *testing.T) {
+	h := NewHash[int]()
+	if _, ok := h.RandomWeighted(); ok {
+		t.Fatal("ok should be false when h is empty")
+	}
+	h.SetRand(rand.New(rand.NewSource(1)))
+	for i := 0; i < 10; i++ {
+		h.AddWeighted(i, i+1)
+	}
+	h.Remove(3)
+	h.SetWeight(5, 2)
+	h.SetWeight(7, 12)
+	h.Add(10)
+
+	const n = 200000
+	var total int
+	for _, obj := range h.All() {
+		total += h.Weight(obj)
+	}
+	counts := make(map[int]int)
+	for i := 0; i < n; i++ {
+		v, ok := h.RandomWeighted()
+		if !ok {
+			t.Fatal("RandomWeighted failed")
+		}
+		counts[v]++
+	}
+	if _, ok := counts[3]; ok {
+		t.Fatal("RandomWeighted should not return a removed object")
+	}
+	for _, obj := range h.All() {
+		expected := float64(n) * float64(h.Weight(obj)) / float64(total)
+		if c := float64(counts[obj]); c < expected*0.9 || c > expected*1.1 {
+			t.Fatalf("RandomWeighted is not proportional to the weights. obj: %d, c: %v, expected: %v", obj, c, expected)
+		}
+	}
+
+	r1, r2 := rand.New(rand.NewSource(2)), rand.New(rand.NewSource(2))
+	h.SetRand(r2)
+	for i := 0; i < 1000; i++ {
+		v1, _ := h.RandomWeightedFrom(r1)
+		v2, _ := h.RandomWeighted()
+		if v1 != v2 {
+			t.Fatal("RandomWeightedFrom and RandomWeighted should agree with the same source")
+		}
+	}
+}
+
+type zeroSource struct{}
+
+func (zeroSource) Intn(int) int {
+	return 0
+}
+
+func TestHash_RandomNFrom(t *testing.T) {
+	h := NewHash[int]()
+	for i := 0; i < 10; i++ {
+		h.Add(i)
+	}
+	all := h.All()
+	if v, ok := h.RandomFrom(zeroSource{}); !ok || v != all[0] {
+		t.Fatalf("RandomFrom should use r. v: %d", v)
+	}
+	if a := h.RandomNFrom(zeroSource{}, 3); !reflect.DeepEqual(a, all[:3]) {
+		t.Fatalf("RandomNFrom should use r. a: %v", a)
+	}
+	if v, ok := h.RandomExceptFrom(zeroSource{}, all[0], all[1]); !ok || v != all[2] {
+		t.Fatalf("RandomExceptFrom should use r. v: %d", v)
+	}
+
+	h.SetRand(zeroSource{})
+	if a := h.RandomN(3); !reflect.DeepEqual(a, all[:3]) {
+		t.Fatalf("RandomN should use the source set by SetRand. a: %v", a)
+	}
+	if v, _ := h.RandomExcept(all[0]); v != all[1] {
+		t.Fatalf("RandomExcept should use the source set by SetRand. v: %d", v)
+	}
+
+	s := NewSyncHashWithOptions[int](WithRand(zeroSource{}))
+	a := NewAtomicHashWithOptions[int](WithRand(zeroSource{}))
+	for i := 0; i < 10; i++ {
+		s.Add(i)
+		a.Add(i)
+	}
+	if v1, v2 := s.RandomNFrom(zeroSource{}, 2), a.RandomNFrom(zeroSource{}, 2); !reflect.DeepEqual(v1, all[:2]) || !reflect.DeepEqual(v2, all[:2]) {
+		t.Fatalf("the wrappers should use r. v1: %v, v2: %v", v1, v2)
+	}
+	v1, _ := s.RandomExceptFrom(zeroSource{}, all[0])
+	v2, _ := a.RandomExceptFrom(zeroSource{}, all[0])
+	if v1 != all[1] || v2 != all[1] {
+		t.Fatalf("the wrappers should use r. v1: %d, v2: %d", v1, v2)
+	}
+}
+
+func TestRandomWrappersConcurrent(t *testing.T) {
+	s := NewSyncHashWithOptions[int](WithRand(rand.New(rand.NewSource(1))))
+	a := NewAtomicHashWithOptions[int](WithRand(rand.New(rand.NewSource(1))))
+	for i := 0; i < 100; i++ {
+		s.AddWeighted(i, i%3+1)
+		a.AddWeighted(i, i%3+1)
+	}
+	a.Update(func(h *Hash[int]) {
+		h.SetRand(rand.New(rand.NewSource(2)))
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				s.Random()
+				s.RandomN(3)
+				s.RandomExcept(1, 2)
+				s.RandomWeighted()
+				a.Random()
+				a.RandomN(3)
+				a.RandomExcept(1, 2)
+				a.RandomWeighted()
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,96 @@
+package doublejump
+
+import (
+	"github.com/dgryski/go-jump"
+)
+
+// probeKey returns the i-th key of the probe sequence of key. The first key of
+// the sequence is key itself.
+func probeKey(key uint64, i int) uint64 {
+	if i == 0 {
+		return key
+	}
+	return mix64(key + uint64(i)*0x9e3779b97f4a7c15)
+}
+
+// mix64 is the finalizer of splitmix64.
+func mix64(z uint64) uint64 {
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// maxProbes returns the number of probes to try before falling back to a
+// linear scan when looking for n distinct objects.
+func maxProbes(n int) int {
+	return n*16 + 64
+}
+
+func contains[T comparable](a []T, obj T) bool {
+	for _, v := range a {
+		if v == obj {
+			return true
+		}
+	}
+	return false
+}
+
+// GetN returns up to n distinct objects for the key, ordered by preference.
+// The first object is always the one returned by Get. See AppendN for details.
+func (h *Hash[T]) GetN(key uint64, n int) []T {
+	if n > h.Len() {
+		n = h.Len()
+	}
+	if n <= 0 {
+		return nil
+	}
+	return h.AppendN(make([]T, 0, n), key, n)
+}
+
+// AppendN appends up to n distinct objects for the key to dst, ordered by
+// preference, and returns the extended slice. The first object is the one
+// returned by Get, and the others follow a probe sequence over the loose slots,
+// so removing an object mostly changes the lists which contain it.
+func (h *Hash[T]) AppendN(dst []T, key uint64, n int) []T {
+	if n > h.Len() {
+		n = h.Len()
+	}
+	if n <= 0 {
+		return dst
+	}
+
+	start := len(dst)
+	for i, limit := 0, maxProbes(n); len(dst)-start < n && i < limit; i++ {
+		if obj, ok := h.probe(key, i); ok && !contains(dst[start:], obj) {
+			dst = append(dst, obj)
+		}
+	}
+	return h.appendRest(dst, start, key, n)
+}
+
+// probe returns the object for the i-th key of the probe sequence of key and
+// reports whether it succeeded. The first probe is the same as Get, and the
+// others only look at the inner loose object holder, failing on empty slots.
+func (h *Hash[T]) probe(key uint64, i int) (T, bool) {
+	if i == 0 {
+		return h.Get(key)
+	}
+	return h.loose.get(probeKey(key, i))
+}
+
+// appendRest fills dst[start:] up to n objects by scanning the slots of the
+// inner loose object holder from a position derived from the key.
+func (h *Hash[T]) appendRest(dst []T, start int, key uint64, n int) []T {
+	if len(dst)-start >= n {
+		return dst
+	}
+
+	c := len(h.loose.a)
+	offset := int(jump.Hash(key, c))
+	for i := 0; i < c && len(dst)-start < n; i++ {
+		if opt := h.loose.a[(offset+i)%c]; opt.b && !contains(dst[start:], opt.v) {
+			dst = append(dst, opt.v)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,202 @@
+package doublejump
+
+import (
+	"testing"
+)
+
+func TestHash_GetN(t *testing.T) {
+	h := NewHash[int]()
+	if a := h.GetN(100, 3); len(a) != 0 {
+		t.Fatal("GetN should return nothing when h is empty")
+	}
+
+	for i := 0; i < 50; i++ {
+		h.Add(i)
+	}
+	for key := uint64(0); key < 10000; key++ {
+		a := h.GetN(key, 5)
+		if len(a) != 5 {
+			t.Fatalf("len(a) != 5. len(a): %d", len(a))
+		}
+		if obj, _ := h.Get(key); a[0] != obj {
+			t.Fatalf("a[0] != h.Get(key). key: %d, a[0]: %d, obj: %d", key, a[0], obj)
+		}
+		m := make(map[int]struct{})
+		for _, obj := range a {
+			m[obj] = struct{}{}
+		}
+		if len(m) != len(a) {
+			t.Fatalf("the objects returned by GetN should be distinct. a: %v", a)
+		}
+		b := h.GetN(key, 5)
+		for i := range a {
+			if a[i] != b[i] {
+				t.Fatalf("GetN should be deterministic. a: %v, b: %v", a, b)
+			}
+		}
+	}
+
+	if a := h.GetN(1, 0); len(a) != 0 {
+		t.Fatal("GetN should return nothing when n is 0")
+	}
+	if a := h.GetN(1, 100); len(a) != 50 {
+		t.Fatalf("GetN should return all objects when n > h.Len(). len(a): %d", len(a))
+	}
+}
+
+func TestHash_GetNStable(t *testing.T) {
+	h := NewHash[int]()
+	for i := 0; i < 50; i++ {
+		h.AddWeighted(i, i%2+1)
+	}
+
+	const total = 10000
+	lists := make([][]int, total)
+	for key := range lists {
+		lists[key] = h.GetN(uint64(key), 3)
+	}
+
+	h.Remove(17)
+	var changed int
+	for key, a := range lists {
+		b := h.GetN(uint64(key), 3)
+		if contains(a, 17) {
+			changed++
+			if contains(b, 17) {
+				t.Fatalf("17 should not be in the list. b: %v", b)
+			}
+		} else {
+			for i := range a {
+				if a[i] != b[i] {
+					t.Fatalf("the lists without 17 should not change. a: %v, b: %v", a, b)
+				}
+			}
+		}
+		lists[key] = b
+	}
+	if changed == 0 {
+		t.Fatal("some lists should contain 17")
+	}
+
+	h.AddWeighted(17, 2)
+	for key, a := range lists {
+		b := h.GetN(uint64(key), 3)
+		if !contains(b, 17) {
+			for i := range a {
+				if a[i] != b[i] {
+					t.Fatalf("the lists without 17 should not change. a: %v, b: %v", a, b)
+				}
+			}
+		}
+	}
+}
+
+func TestHash_GetNStableWithHoles(t *testing.T) {
+	h := NewHash[int]()
+	for i := 0; i < 50; i++ {
+		h.AddWeighted(i, i%2+1)
+	}
+	for _, obj := range []int{5, 22, 31, 48} {
+		h.Remove(obj)
+	}
+	if len(h.loose.f) == 0 {
+		t.Fatal("the test case is too weak")
+	}
+
+	const total = 10000
+	owners := make([]int, total)
+	lists := make([][]int, total)
+	for key := range lists {
+		owners[key], _ = h.Get(uint64(key))
+		lists[key] = h.GetN(uint64(key), 3)
+	}
+
+	h.Remove(17)
+	var changed, remapped int
+	for key, a := range lists {
+		b := h.GetN(uint64(key), 3)
+		if contains(b, 17) {
+			t.Fatalf("17 should not be in the list. b: %v", b)
+		}
+		if obj, _ := h.Get(uint64(key)); obj != owners[key] && owners[key] != 17 {
+			remapped++
+			continue
+		}
+		if contains(a, 17) {
+			changed++
+			i := 1
+			for _, obj := range a {
+				if obj != 17 && obj != b[0] {
+					if obj != b[i] {
+						t.Fatalf("17 should be shifted out. a: %v, b: %v", a, b)
+					}
+					i++
+				}
+			}
+		} else {
+			for i := range a {
+				if a[i] != b[i] {
+					t.Fatalf("the lists without 17 should not change. a: %v, b: %v", a, b)
+				}
+			}
+		}
+	}
+	if changed == 0 {
+		t.Fatal("some lists should contain 17")
+	}
+	if remapped > total/100 {
+		t.Fatalf("too many keys are remapped by Get. remapped: %d", remapped)
+	}
+}
+
+func TestHash_AppendN(t *testing.T) {
+	h := NewHash[int]()
+	for i := 0; i < 10; i++ {
+		h.Add(i)
+	}
+
+	dst := []int{-1}
+	dst = h.AppendN(dst, 100, 3)
+	if len(dst) != 4 || dst[0] != -1 {
+		t.Fatalf("something is wrong with AppendN. dst: %v", dst)
+	}
+	a := h.GetN(100, 3)
+	for i := range a {
+		if a[i] != dst[i+1] {
+			t.Fatalf("AppendN and GetN should return the same objects. a: %v, dst: %v", a, dst)
+		}
+	}
+
+	all := h.AppendN(nil, 100, 10)
+	if len(all) != 10 {
+		t.Fatalf("len(all) != 10. len(all): %d", len(all))
+	}
+
+	buf := make([]int, 0, 3)
+	var key uint64
+	allocs := testing.AllocsPerRun(1000, func() {
+		key++
+		buf = h.AppendN(buf[:0], key, 3)
+	})
+	if allocs != 0 {
+		t.Fatalf("AppendN should not allocate. allocs: %v", allocs)
+	}
+}
+
+func TestHash_AppendRest(t *testing.T) {
+	h := NewHash[int]()
+	for i := 0; i < 10; i++ {
+		h.Add(i)
+	}
+	a := h.appendRest([]int{3, 5}, 0, 100, 10)
+	if len(a) != 10 || a[0] != 3 || a[1] != 5 {
+		t.Fatalf("something is wrong with appendRest. a: %v", a)
+	}
+	m := make(map[int]struct{})
+	for _, obj := range a {
+		m[obj] = struct{}{}
+	}
+	if len(m) != 10 {
+		t.Fatalf("the objects returned by appendRest should be distinct. a: %v", a)
+	}
+}
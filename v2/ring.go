@@ -0,0 +1,131 @@
+package doublejump
+
+import (
+	"github.com/dgryski/go-jump"
+)
+
+// freeSlot is a node of the persistent stack of the empty loose slots.
+type freeSlot struct {
+	idx  int
+	next *freeSlot
+}
+
+// Ring is an immutable doublejump hash. With and Without return new versions
+// of the ring instead of modifying it, and every version shares most of its
+// structure with the one it derives from, so keeping a long history of
+// versions is cheap. The holders are persistent vectors and the indices are
+// persistent hash maps, thus a lookup takes a few more memory accesses than
+// Hash.Get, and a modification allocates O(log n) memory.
+//
+// A Ring maps every key to the same object as a Hash created by NewHash would
+// after the same sequence of Add and Remove. Weights are not supported.
+//
+// Ring is thread-safe, since it is immutable.
+type Ring[T comparable] struct {
+	loose      pvec[optional[T]]
+	free       *freeSlot
+	looseIdx   hamt[T, int]
+	compact    pvec[T]
+	compactIdx hamt[T, int]
+	hash       func(T) uint64
+}
+
+// NewRing creates an empty immutable ring. hash is used to index the objects,
+// so it must return the same value for the same object. It needs not be
+// collision-free, but a good distribution keeps the operations fast.
+func NewRing[T comparable](hash func(T) uint64) *Ring[T] {
+	return &Ring[T]{hash: hash}
+}
+
+// With returns a version of the ring with obj added. It returns r itself if
+// obj is already in the ring.
+func (r *Ring[T]) With(obj T) *Ring[T] {
+	hash := r.hash(obj)
+	if _, ok := r.compactIdx.get(hash, obj); ok {
+		return r
+	}
+
+	c := *r
+	slot := optional[T]{v: obj, b: true}
+	if c.free != nil {
+		idx := c.free.idx
+		c.free = c.free.next
+		c.loose = c.loose.set(idx, slot)
+		c.looseIdx = c.looseIdx.set(hash, obj, idx)
+	} else {
+		c.looseIdx = c.looseIdx.set(hash, obj, c.loose.size)
+		c.loose = c.loose.push(slot)
+	}
+	c.compactIdx = c.compactIdx.set(hash, obj, c.compact.size)
+	c.compact = c.compact.push(obj)
+	return &c
+}
+
+// Without returns a version of the ring with obj removed. It returns r itself
+// if obj is not in the ring.
+func (r *Ring[T]) Without(obj T) *Ring[T] {
+	hash := r.hash(obj)
+	idx, ok := r.compactIdx.get(hash, obj)
+	if !ok {
+		return r
+	}
+
+	c := *r
+	looseIdx, _ := c.looseIdx.get(hash, obj)
+	c.loose = c.loose.set(looseIdx, optional[T]{})
+	c.free = &freeSlot{idx: looseIdx, next: c.free}
+	c.looseIdx = c.looseIdx.delete(hash, obj)
+
+	last := c.compact.size - 1
+	if idx != last {
+		tail := c.compact.get(last)
+		c.compact = c.compact.set(idx, tail)
+		c.compactIdx = c.compactIdx.set(r.hash(tail), tail, idx)
+	}
+	c.compact = c.compact.pop()
+	c.compactIdx = c.compactIdx.delete(hash, obj)
+	return &c
+}
+
+// Contains reports whether obj is in the ring.
+func (r *Ring[T]) Contains(obj T) bool {
+	_, ok := r.compactIdx.get(r.hash(obj), obj)
+	return ok
+}
+
+// Len returns the number of objects in the ring.
+func (r *Ring[T]) Len() int {
+	return r.compact.size
+}
+
+// LooseLen returns the size of the inner loose object holder.
+func (r *Ring[T]) LooseLen() int {
+	return r.loose.size
+}
+
+// Get returns the existing object for the key and reports whether it succeeded.
+func (r *Ring[T]) Get(key uint64) (obj T, ok bool) {
+	c := r.compact.size
+	if c == 0 {
+		return obj, false
+	}
+	if n := r.loose.size; n > 0 {
+		if slot := r.loose.get(int(jump.Hash(key, n))); slot.b {
+			return slot.v, true
+		}
+	}
+	return r.compact.get(int(jump.Hash(key*DefaultCompactMultiplier, c))), true
+}
+
+// All returns all the objects in the ring.
+func (r *Ring[T]) All() []T {
+	n := r.compact.size
+	if n == 0 {
+		return nil
+	}
+	all := make([]T, n)
+	for i := range all {
+		all[i] = r.compact.get(i)
+	}
+	return all
+}
@@ -0,0 +1,109 @@
+package doublejump
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func checkSameMapping(h *Hash[int], r *Ring[int], t *testing.T) {
+	t.Helper()
+	if h.Len() != r.Len() || h.LooseLen() != r.LooseLen() {
+		t.Fatalf("the sizes are different. h: %d/%d, r: %d/%d", h.Len(), h.LooseLen(), r.Len(), r.LooseLen())
+	}
+	all1, all2 := h.All(), r.All()
+	for i := range all1 {
+		if all1[i] != all2[i] {
+			t.Fatalf("the objects are different. all1: %v, all2: %v", all1, all2)
+		}
+		if !r.Contains(all1[i]) {
+			t.Fatalf("r should contain %d", all1[i])
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		key := uint64(i) * 0x9e3779b97f4a7c15
+		v1, ok1 := h.Get(key)
+		v2, ok2 := r.Get(key)
+		if v1 != v2 || ok1 != ok2 {
+			t.Fatalf("h.Get(%d) != r.Get(%d). v1: %d, v2: %d", key, key, v1, v2)
+		}
+	}
+}
+
+func TestRing(t *testing.T) {
+	hash := func(obj int) uint64 {
+		return mix64(uint64(obj))
+	}
+	r0 := NewRing[int](hash)
+	if _, ok := r0.Get(1); ok {
+		t.Fatal("ok should be false when r is empty")
+	}
+	if r0.All() != nil || r0.Len() != 0 {
+		t.Fatal("r should be empty")
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	h := NewHash[int]()
+	r := r0
+	type version struct {
+		h *Hash[int]
+		r *Ring[int]
+	}
+	var versions []version
+	for i := 0; i < 3000; i++ {
+		obj := rnd.Intn(500)
+		if rnd.Intn(3) == 0 {
+			h.Remove(obj)
+			r = r.Without(obj)
+		} else {
+			h.Add(obj)
+			r = r.With(obj)
+		}
+		if i%100 == 0 {
+			checkSameMapping(h, r, t)
+			versions = append(versions, version{h: h.clone(), r: r})
+		}
+	}
+	if len(h.loose.f) == 0 {
+		t.Fatal("the test case is too weak")
+	}
+	for _, ver := range versions {
+		checkSameMapping(ver.h, ver.r, t)
+	}
+
+	if r.With(h.All()[0]) != r || r.Without(-1) != r {
+		t.Fatal("With and Without should return r itself when nothing changes")
+	}
+	for _, obj := range h.All() {
+		r = r.Without(obj)
+	}
+	if r.Len() != 0 || r.All() != nil {
+		t.Fatal("r should be empty")
+	}
+	if _, ok := r.Get(1); ok {
+		t.Fatal("ok should be false when r is empty")
+	}
+}
+
+func BenchmarkRing_Get(b *testing.B) {
+	h, keys := benchmarkHash(1000)
+	r := NewRing[int](func(obj int) uint64 {
+		return mix64(uint64(obj))
+	})
+	for i := 0; i < 2000; i++ {
+		r = r.With(i)
+	}
+	for i := 0; i < 2000; i += 2 {
+		r = r.Without(i)
+	}
+	if r.Len() != h.Len() {
+		b.Fatal("the ring is different from the hash")
+	}
+	out := make([]int, len(keys))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j, key := range keys {
+			out[j], _ = r.Get(key)
+		}
+	}
+}
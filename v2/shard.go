@@ -0,0 +1,66 @@
+package doublejump
+
+// tenantSalt separates the probe sequences of the tenants from those of the
+// keys, so that the shard of a tenant is unrelated to the owner of the same
+// value used as a key.
+const tenantSalt = 0x2545f4914f6cdd1d
+
+func tenantKey(tenantID uint64) uint64 {
+	return mix64(tenantID ^ tenantSalt)
+}
+
+// Shard returns the shuffle shard of a tenant, i.e. a deterministic subset of
+// up to size objects. Different tenants get different, mostly overlapping
+// subsets, so a noisy tenant can only affect the objects of its own shard.
+// Unlike GetN, Shard walks the probe sequence of the tenant over the slots of
+// the inner loose object holder only, so it does not depend on the compact
+// fallback: removing an object only changes the shards which contain it, each
+// by a single member, and adding an object only changes the shards it joins.
+func (h *Hash[T]) Shard(tenantID uint64, size int) []T {
+	if size > h.Len() {
+		size = h.Len()
+	}
+	if size <= 0 {
+		return nil
+	}
+	return h.appendShard(make([]T, 0, size), tenantKey(tenantID), size)
+}
+
+func (h *Hash[T]) appendShard(dst []T, key uint64, n int) []T {
+	for i, limit := 0, maxProbes(n); len(dst) < n && i < limit; i++ {
+		if obj, ok := h.loose.get(probeKey(key, i)); ok && !contains(dst, obj) {
+			dst = append(dst, obj)
+		}
+	}
+	return h.appendRest(dst, 0, key, n)
+}
+
+// GetInShard returns the object for the key among the shuffle shard of a
+// tenant and reports whether it succeeded. The objects of the shard are
+// ranked by rendezvous hashing, which identifies each object by its slot in
+// the inner loose object holder, so a change of the shard only remaps the
+// keys of the objects leaving or joining it.
+func (h *Hash[T]) GetInShard(tenantID uint64, size int, key uint64) (obj T, ok bool) {
+	if size > h.Len() {
+		size = h.Len()
+	}
+	if size <= 0 {
+		return obj, false
+	}
+	if size > 16 {
+		return h.pickInShard(h.Shard(tenantID, size), key), true
+	}
+	var buf [16]T
+	return h.pickInShard(h.appendShard(buf[:0], tenantKey(tenantID), size), key), true
+}
+
+func (h *Hash[T]) pickInShard(shard []T, key uint64) (obj T) {
+	var best uint64
+	for i, v := range shard {
+		score := mix64(key + uint64(h.loose.m[v]+1)*0x9e3779b97f4a7c15)
+		if i == 0 || score > best {
+			obj, best = v, score
+		}
+	}
+	return obj
+}
@@ -0,0 +1,172 @@
+package doublejump
+
+import (
+	"testing"
+)
+
+func TestHash_Shard(t *testing.T) {
+	h := NewHash[int]()
+	if a := h.Shard(1, 3); len(a) != 0 {
+		t.Fatal("Shard should return nothing when h is empty")
+	}
+	if _, ok := h.GetInShard(1, 3, 100); ok {
+		t.Fatal("ok should be false when h is empty")
+	}
+
+	for i := 0; i < 100; i++ {
+		h.Add(i)
+	}
+	const numTenants = 1000
+	shards := make([][]int, numTenants)
+	counts := make([]int, 100)
+	distinct := make(map[[4]int]struct{})
+	for tenant := range shards {
+		a := h.Shard(uint64(tenant), 4)
+		if len(a) != 4 {
+			t.Fatalf("len(a) != 4. len(a): %d", len(a))
+		}
+		for _, obj := range a {
+			counts[obj]++
+		}
+		distinct[*(*[4]int)(a)] = struct{}{}
+		shards[tenant] = a
+	}
+	if len(distinct) < numTenants*9/10 {
+		t.Fatalf("the shards should rarely be the same. distinct: %d", len(distinct))
+	}
+	for obj, c := range counts {
+		if c < 10 || c > 80 {
+			t.Fatalf("the shards are unbalanced. obj: %d, c: %d", obj, c)
+		}
+	}
+
+	h.Remove(42)
+	for tenant, a := range shards {
+		b := h.Shard(uint64(tenant), 4)
+		var diff int
+		for _, obj := range b {
+			if !contains(a, obj) {
+				diff++
+			}
+		}
+		switch {
+		case contains(b, 42):
+			t.Fatalf("42 should not be in the shard. b: %v", b)
+		case contains(a, 42) && diff != 1:
+			t.Fatalf("the shard should change by one member. a: %v, b: %v", a, b)
+		case !contains(a, 42) && diff != 0:
+			t.Fatalf("the shard should not change. a: %v, b: %v", a, b)
+		}
+	}
+}
+
+func TestHash_ShardStableWithHoles(t *testing.T) {
+	h := NewHash[int]()
+	for i := 0; i < 100; i++ {
+		h.AddWeighted(i, i%3+1)
+	}
+	for _, obj := range []int{7, 33, 61, 88} {
+		h.Remove(obj)
+	}
+	if len(h.loose.f) == 0 {
+		t.Fatal("the test case is too weak")
+	}
+
+	const numTenants = 10000
+	shards := make([][]int, numTenants)
+	for tenant := range shards {
+		shards[tenant] = h.Shard(uint64(tenant), 4)
+	}
+
+	h.Remove(41)
+	var changed int
+	for tenant, a := range shards {
+		b := h.Shard(uint64(tenant), 4)
+		var diff int
+		for _, obj := range b {
+			if !contains(a, obj) {
+				diff++
+			}
+		}
+		switch {
+		case contains(b, 41):
+			t.Fatalf("41 should not be in the shard. b: %v", b)
+		case contains(a, 41) && diff != 1:
+			t.Fatalf("the shard should change by one member. a: %v, b: %v", a, b)
+		case !contains(a, 41) && diff != 0:
+			t.Fatalf("the shard should not change. a: %v, b: %v", a, b)
+		}
+		if diff != 0 {
+			changed++
+		}
+		shards[tenant] = b
+	}
+	if changed == 0 {
+		t.Fatal("some shards should contain 41")
+	}
+
+	h.AddWeighted(41, 2)
+	for tenant, a := range shards {
+		b := h.Shard(uint64(tenant), 4)
+		if !contains(b, 41) {
+			for i := range a {
+				if a[i] != b[i] {
+					t.Fatalf("the shards without 41 should not change. a: %v, b: %v", a, b)
+				}
+			}
+		}
+	}
+}
+
+func TestHash_GetInShard(t *testing.T) {
+	h := NewHash[int]()
+	for i := 0; i < 100; i++ {
+		h.Add(i)
+	}
+
+	const tenant, size = 7, 5
+	shard := h.Shard(tenant, size)
+	owners := make(map[uint64]int)
+	counts := make(map[int]int)
+	for key := uint64(0); key < 10000; key++ {
+		obj, ok := h.GetInShard(tenant, size, key)
+		if !ok || !contains(shard, obj) {
+			t.Fatalf("GetInShard should return a member of the shard. obj: %d, shard: %v", obj, shard)
+		}
+		owners[key] = obj
+		counts[obj]++
+	}
+	for _, obj := range shard {
+		if c := counts[obj]; c < 1600 || c > 2400 {
+			t.Fatalf("the keys are unbalanced in the shard. obj: %d, c: %d", obj, c)
+		}
+	}
+
+	victim := shard[2]
+	h.Remove(victim)
+	var newcomer int
+	for _, obj := range h.Shard(tenant, size) {
+		if !contains(shard, obj) {
+			newcomer = obj
+		}
+	}
+	for key, owner := range owners {
+		obj, _ := h.GetInShard(tenant, size, key)
+		if owner != victim && obj != owner && obj != newcomer {
+			t.Fatalf("only the keys of the leaving and joining objects should move. key: %d, owner: %d, obj: %d", key, owner, obj)
+		}
+	}
+
+	if obj, ok := h.GetInShard(tenant, 1000, 1); !ok || h.Weight(obj) == 0 {
+		t.Fatal("GetInShard should clamp the size")
+	}
+	if _, ok := h.GetInShard(tenant, 0, 1); ok {
+		t.Fatal("ok should be false when size is 0")
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		h.GetInShard(tenant, size, 1)
+	})
+	if allocs != 0 {
+		t.Fatalf("GetInShard should not allocate for small shards. allocs: %v", allocs)
+	}
+}
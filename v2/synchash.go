@@ -0,0 +1,403 @@
+package doublejump
+
+import (
+	"sync"
+)
+
+// SyncHash is a thread-safe wrapper of Hash. Lookups run in parallel, while
+// modifications are serialized.
+type SyncHash[T comparable] struct {
+	mu sync.RWMutex
+	h  *Hash[T]
+}
+
+// NewSyncHash creates a new thread-safe doublejump hash instance.
+func NewSyncHash[T comparable]() *SyncHash[T] {
+	return &SyncHash[T]{h: NewHash[T]()}
+}
+
+// NewSyncHashWithKeyHasher creates a new thread-safe doublejump hash instance
+// which uses hasher to turn string and byte-slice keys into uint64 keys.
+func NewSyncHashWithKeyHasher[T comparable](hasher KeyHasher) *SyncHash[T] {
+	return &SyncHash[T]{h: NewHashWithKeyHasher[T](hasher)}
+}
+
+// NewSyncHashWithOptions creates a new thread-safe doublejump hash instance
+// with options, e.g. WithKeyHasher and WithHashTags.
+func NewSyncHashWithOptions[T comparable](opts ...Option) *SyncHash[T] {
+	h := NewHashWithOptions[T](opts...)
+	h.lockRand()
+	return &SyncHash[T]{h: h}
+}
+
+// Add adds an object to the hash.
+func (s *SyncHash[T]) Add(obj T) {
+	s.mu.Lock()
+	s.h.Add(obj)
+	s.mu.Unlock()
+}
+
+// AddWeighted adds an object to the hash with the given weight.
+func (s *SyncHash[T]) AddWeighted(obj T, weight int) {
+	s.mu.Lock()
+	s.h.AddWeighted(obj, weight)
+	s.mu.Unlock()
+}
+
+// SetWeight changes the weight of an existing object.
+func (s *SyncHash[T]) SetWeight(obj T, weight int) {
+	s.mu.Lock()
+	s.h.SetWeight(obj, weight)
+	s.mu.Unlock()
+}
+
+// Weight returns the weight of an object, or 0 if the object is not in the hash.
+func (s *SyncHash[T]) Weight(obj T) int {
+	s.mu.RLock()
+	w := s.h.Weight(obj)
+	s.mu.RUnlock()
+	return w
+}
+
+// Remove removes an object from the hash.
+func (s *SyncHash[T]) Remove(obj T) {
+	s.mu.Lock()
+	s.h.Remove(obj)
+	s.mu.Unlock()
+}
+
+// AddAll adds the objects to the hash in order.
+func (s *SyncHash[T]) AddAll(objs []T) {
+	s.mu.Lock()
+	s.h.AddAll(objs)
+	s.mu.Unlock()
+}
+
+// RemoveAll removes the objects from the hash in order.
+func (s *SyncHash[T]) RemoveAll(objs []T) {
+	s.mu.Lock()
+	s.h.RemoveAll(objs)
+	s.mu.Unlock()
+}
+
+// ReplaceAll makes objs the exact content of the hash with the fewest changes.
+func (s *SyncHash[T]) ReplaceAll(objs []T) {
+	s.mu.Lock()
+	s.h.ReplaceAll(objs)
+	s.mu.Unlock()
+}
+
+// Pin overrides the hash for the key with obj.
+func (s *SyncHash[T]) Pin(key uint64, obj T) error {
+	s.mu.Lock()
+	err := s.h.Pin(key, obj)
+	s.mu.Unlock()
+	return err
+}
+
+// Unpin removes the pin of the key and reports whether the key was pinned.
+func (s *SyncHash[T]) Unpin(key uint64) bool {
+	s.mu.Lock()
+	ok := s.h.Unpin(key)
+	s.mu.Unlock()
+	return ok
+}
+
+// Pinned returns the object the key is pinned to, and reports whether the key
+// is pinned.
+func (s *SyncHash[T]) Pinned(key uint64) (obj T, ok bool) {
+	s.mu.RLock()
+	obj, ok = s.h.Pinned(key)
+	s.mu.RUnlock()
+	return
+}
+
+// MarkDown marks an object as unhealthy.
+func (s *SyncHash[T]) MarkDown(obj T) {
+	s.mu.Lock()
+	s.h.MarkDown(obj)
+	s.mu.Unlock()
+}
+
+// MarkUp marks an object as healthy again.
+func (s *SyncHash[T]) MarkUp(obj T) {
+	s.mu.Lock()
+	s.h.MarkUp(obj)
+	s.mu.Unlock()
+}
+
+// IsDown reports whether an object is marked as unhealthy.
+func (s *SyncHash[T]) IsDown(obj T) bool {
+	s.mu.RLock()
+	down := s.h.IsDown(obj)
+	s.mu.RUnlock()
+	return down
+}
+
+// GetHealthy returns the first healthy object for the key and reports whether
+// it succeeded.
+func (s *SyncHash[T]) GetHealthy(key uint64) (obj T, ok bool) {
+	s.mu.RLock()
+	obj, ok = s.h.GetHealthy(key)
+	s.mu.RUnlock()
+	return
+}
+
+// Drain puts an object into the draining state.
+func (s *SyncHash[T]) Drain(obj T) {
+	s.mu.Lock()
+	s.h.Drain(obj)
+	s.mu.Unlock()
+}
+
+// IsDraining reports whether an object is in the draining state.
+func (s *SyncHash[T]) IsDraining(obj T) bool {
+	s.mu.RLock()
+	draining := s.h.IsDraining(obj)
+	s.mu.RUnlock()
+	return draining
+}
+
+// Draining returns the draining objects in the order they were drained.
+func (s *SyncHash[T]) Draining() []T {
+	s.mu.RLock()
+	a := s.h.Draining()
+	s.mu.RUnlock()
+	return a
+}
+
+// CancelDrain brings a draining object back to the normal state.
+func (s *SyncHash[T]) CancelDrain(obj T) {
+	s.mu.Lock()
+	s.h.CancelDrain(obj)
+	s.mu.Unlock()
+}
+
+// FinishDrain removes a draining object from the hash and reports whether it
+// succeeded.
+func (s *SyncHash[T]) FinishDrain(obj T) bool {
+	s.mu.Lock()
+	ok := s.h.FinishDrain(obj)
+	s.mu.Unlock()
+	return ok
+}
+
+// GetNew returns the object for the key as if all the draining objects had
+// been removed, and reports whether it succeeded.
+func (s *SyncHash[T]) GetNew(key uint64) (obj T, ok bool) {
+	s.mu.RLock()
+	obj, ok = s.h.GetNew(key)
+	s.mu.RUnlock()
+	return
+}
+
+// StickyKeys returns the keys in the given set which are still mapped to the
+// object by Get.
+func (s *SyncHash[T]) StickyKeys(obj T, keys []uint64) []uint64 {
+	s.mu.RLock()
+	a := s.h.StickyKeys(obj, keys)
+	s.mu.RUnlock()
+	return a
+}
+
+// OnChange registers fn to be called after every modification of the hash.
+// fn is called with the write lock held, so it must not call any method of s.
+func (s *SyncHash[T]) OnChange(fn func(Change[T])) {
+	s.mu.Lock()
+	s.h.OnChange(fn)
+	s.mu.Unlock()
+}
+
+// Replace puts a new object into exactly the slots of an old one and reports
+// whether it succeeded.
+func (s *SyncHash[T]) Replace(old, new T) bool {
+	s.mu.Lock()
+	ok := s.h.Replace(old, new)
+	s.mu.Unlock()
+	return ok
+}
+
+// Len returns the number of objects in the hash.
+func (s *SyncHash[T]) Len() int {
+	s.mu.RLock()
+	n := s.h.Len()
+	s.mu.RUnlock()
+	return n
+}
+
+// LooseLen returns the size of the inner loose object holder.
+func (s *SyncHash[T]) LooseLen() int {
+	s.mu.RLock()
+	n := s.h.LooseLen()
+	s.mu.RUnlock()
+	return n
+}
+
+// Shrink removes all empty slots from the hash.
+func (s *SyncHash[T]) Shrink() {
+	s.mu.Lock()
+	s.h.Shrink()
+	s.mu.Unlock()
+}
+
+// Get returns the existing object for the key and reports whether it succeeded.
+func (s *SyncHash[T]) Get(key uint64) (obj T, ok bool) {
+	s.mu.RLock()
+	obj, ok = s.h.Get(key)
+	s.mu.RUnlock()
+	return
+}
+
+// GetString hashes the key with the KeyHasher of the hash, then returns the
+// existing object for it and reports whether it succeeded.
+func (s *SyncHash[T]) GetString(key string) (obj T, ok bool) {
+	s.mu.RLock()
+	obj, ok = s.h.GetString(key)
+	s.mu.RUnlock()
+	return
+}
+
+// GetBytes hashes the key with the KeyHasher of the hash, then returns the
+// existing object for it and reports whether it succeeded.
+func (s *SyncHash[T]) GetBytes(key []byte) (obj T, ok bool) {
+	s.mu.RLock()
+	obj, ok = s.h.GetBytes(key)
+	s.mu.RUnlock()
+	return
+}
+
+// SameOwner reports whether all the keys are mapped to the same object by
+// GetString.
+func (s *SyncHash[T]) SameOwner(keys ...string) bool {
+	s.mu.RLock()
+	same := s.h.SameOwner(keys...)
+	s.mu.RUnlock()
+	return same
+}
+
+// GetN returns up to n distinct objects for the key, ordered by preference.
+func (s *SyncHash[T]) GetN(key uint64, n int) []T {
+	s.mu.RLock()
+	a := s.h.GetN(key, n)
+	s.mu.RUnlock()
+	return a
+}
+
+// AppendN appends up to n distinct objects for the key to dst, ordered by
+// preference, and returns the extended slice.
+func (s *SyncHash[T]) AppendN(dst []T, key uint64, n int) []T {
+	s.mu.RLock()
+	dst = s.h.AppendN(dst, key, n)
+	s.mu.RUnlock()
+	return dst
+}
+
+// GetBatch resolves keys[i] into out[i] and reports the result of each key in
+// ok. The read lock is taken once for the whole batch.
+func (s *SyncHash[T]) GetBatch(keys []uint64, out []T, ok []bool) []bool {
+	s.mu.RLock()
+	ok = s.h.GetBatch(keys, out, ok)
+	s.mu.RUnlock()
+	return ok
+}
+
+// GetNDiverse is like GetN, but spreads the objects over the failure domains
+// reported by domain.
+func (s *SyncHash[T]) GetNDiverse(key uint64, n int, domain func(T) string) []T {
+	s.mu.RLock()
+	a := s.h.GetNDiverse(key, n, domain)
+	s.mu.RUnlock()
+	return a
+}
+
+// Shard returns the shuffle shard of a tenant.
+func (s *SyncHash[T]) Shard(tenantID uint64, size int) []T {
+	s.mu.RLock()
+	a := s.h.Shard(tenantID, size)
+	s.mu.RUnlock()
+	return a
+}
+
+// GetInShard returns the object for the key among the shuffle shard of a
+// tenant and reports whether it succeeded.
+func (s *SyncHash[T]) GetInShard(tenantID uint64, size int, key uint64) (obj T, ok bool) {
+	s.mu.RLock()
+	obj, ok = s.h.GetInShard(tenantID, size, key)
+	s.mu.RUnlock()
+	return
+}
+
+// All returns all the objects in the hash.
+func (s *SyncHash[T]) All() []T {
+	s.mu.RLock()
+	all := s.h.All()
+	s.mu.RUnlock()
+	return all
+}
+
+// Random returns a random object and reports whether it succeeded.
+func (s *SyncHash[T]) Random() (obj T, ok bool) {
+	s.mu.RLock()
+	obj, ok = s.h.Random()
+	s.mu.RUnlock()
+	return
+}
+
+// RandomFrom returns a random object chosen with r and reports whether it
+// succeeded. r must not be shared by concurrent callers.
+func (s *SyncHash[T]) RandomFrom(r Intner) (obj T, ok bool) {
+	s.mu.RLock()
+	obj, ok = s.h.RandomFrom(r)
+	s.mu.RUnlock()
+	return
+}
+
+// RandomN returns n distinct random objects.
+func (s *SyncHash[T]) RandomN(n int) []T {
+	s.mu.RLock()
+	a := s.h.RandomN(n)
+	s.mu.RUnlock()
+	return a
+}
+
+// RandomNFrom is like RandomN but chooses the objects with r.
+func (s *SyncHash[T]) RandomNFrom(r Intner, n int) []T {
+	s.mu.RLock()
+	a := s.h.RandomNFrom(r, n)
+	s.mu.RUnlock()
+	return a
+}
+
+// RandomExcept returns a random object other than the excluded ones and
+// reports whether it succeeded.
+func (s *SyncHash[T]) RandomExcept(exclude ...T) (obj T, ok bool) {
+	s.mu.RLock()
+	obj, ok = s.h.RandomExcept(exclude...)
+	s.mu.RUnlock()
+	return
+}
+
+// RandomExceptFrom is like RandomExcept but chooses the object with r.
+func (s *SyncHash[T]) RandomExceptFrom(r Intner, exclude ...T) (obj T, ok bool) {
+	s.mu.RLock()
+	obj, ok = s.h.RandomExceptFrom(r, exclude...)
+	s.mu.RUnlock()
+	return
+}
+
+// RandomWeighted returns a random object with a probability proportional to
+// its weight and reports whether it succeeded.
+func (s *SyncHash[T]) RandomWeighted() (obj T, ok bool) {
+	s.mu.RLock()
+	obj, ok = s.h.RandomWeighted()
+	s.mu.RUnlock()
+	return
+}
+
+// RandomWeightedFrom is like RandomWeighted but chooses the object with r.
+func (s *SyncHash[T]) RandomWeightedFrom(r Intner) (obj T, ok bool) {
+	s.mu.RLock()
+	obj, ok = s.h.RandomWeightedFrom(r)
+	s.mu.RUnlock()
+	return
+}
@@ -0,0 +1,118 @@
+package doublejump
+
+import (
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSyncHash_Basic(t *testing.T) {
+	s := NewSyncHash[int]()
+	if _, ok := s.Get(100); ok {
+		t.Fatal("something is wrong with Get")
+	}
+	if _, ok := s.Random(); ok {
+		t.Fatal("ok should be false when s is empty")
+	}
+
+	for i := 0; i < 10; i++ {
+		s.Add(i)
+	}
+	if s.Len() != 10 || s.LooseLen() != 10 || len(s.All()) != 10 {
+		t.Fatal("something is wrong with Add")
+	}
+
+	s.Remove(3)
+	s.Remove(7)
+	if s.Len() != 8 || s.LooseLen() != 10 {
+		t.Fatal("something is wrong with Remove")
+	}
+	s.Shrink()
+	if s.Len() != 8 || s.LooseLen() != 8 {
+		t.Fatal("something is wrong with Shrink")
+	}
+	invariant(s.h, t)
+
+	for i := 0; i < 1000; i++ {
+		v, ok := s.Get(uint64(i))
+		if !ok || v == 3 || v == 7 {
+			t.Fatalf("something is wrong with Get. v: %d, ok: %v", v, ok)
+		}
+		if v, ok := s.Random(); !ok || v == 3 || v == 7 {
+			t.Fatalf("something is wrong with Random. v: %d, ok: %v", v, ok)
+		}
+	}
+}
+
+//gocyclo:ignore
+func TestSyncHash_Concurrent(t *testing.T) {
+	const numStable = 10
+	const numVolatile = 100
+	s := NewSyncHash[int]()
+	for i := 0; i < numStable; i++ {
+		s.Add(i)
+	}
+
+	var stop int32
+	var wg sync.WaitGroup
+	chErr := make(chan string, 64)
+	numReaders := runtime.NumCPU()
+	if numReaders < 2 {
+		numReaders = 2
+	}
+	for i := 0; i < numReaders; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for atomic.LoadInt32(&stop) == 0 {
+				for j := 0; j < 1000; j++ {
+					if _, ok := s.Get(r.Uint64()); !ok {
+						chErr <- "something is wrong with Get"
+						return
+					}
+				}
+				if _, ok := s.GetNew(r.Uint64()); !ok {
+					chErr <- "something is wrong with GetNew"
+					return
+				}
+				if _, ok := s.Random(); !ok {
+					chErr <- "something is wrong with Random"
+					return
+				}
+				if len(s.All()) < numStable || s.Len() < numStable || s.LooseLen() < numStable {
+					chErr <- "stable objects are missing"
+					return
+				}
+			}
+		}(int64(i))
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		obj := numStable + r.Intn(numVolatile)
+		switch r.Intn(10) {
+		case 0:
+			s.Shrink()
+		case 1:
+			s.Drain(obj)
+		case 2:
+			s.FinishDrain(obj)
+		case 3, 4:
+			s.Remove(obj)
+		default:
+			s.Add(obj)
+		}
+	}
+	atomic.StoreInt32(&stop, 1)
+	wg.Wait()
+
+	select {
+	case msg := <-chErr:
+		t.Fatal(msg)
+	default:
+	}
+	invariant(s.h, t)
+}
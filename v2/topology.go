@@ -0,0 +1,165 @@
+package doublejump
+
+const (
+	rackSalt = 0x8ebc6af09c88c6e3
+	nodeSalt = 0x589965cc75374cc3
+)
+
+type rackID struct {
+	zone string
+	rack string
+}
+
+// Topology is a hierarchical ring which places the keys in three levels: a
+// key picks a zone first, then a rack in the zone, and finally a node in the
+// rack. Every level is a doublejump Hash, so removing a node only remaps the
+// keys of its own rack, unless the rack becomes empty, in which case the keys
+// of the rack are spread over the other racks of the zone, and so on. Zones
+// and racks are picked uniformly, so they should be of similar sizes.
+//
+// Topology is NOT thread-safe.
+type Topology[T comparable] struct {
+	zones *Hash[string]
+	racks map[string]*Hash[string]
+	nodes map[rackID]*Hash[T]
+	where map[T]rackID
+}
+
+// NewTopology creates a new hierarchical ring.
+func NewTopology[T comparable]() *Topology[T] {
+	return &Topology[T]{
+		zones: NewHash[string](),
+		racks: make(map[string]*Hash[string]),
+		nodes: make(map[rackID]*Hash[T]),
+		where: make(map[T]rackID),
+	}
+}
+
+// Add adds a node to the given rack of the given zone. The zone and the rack
+// are created on demand. It fails if the node is already in the topology.
+func (t *Topology[T]) Add(zone, rack string, node T) error {
+	if _, ok := t.where[node]; ok {
+		return ErrObjectExists
+	}
+
+	id := rackID{zone: zone, rack: rack}
+	racks, ok := t.racks[zone]
+	if !ok {
+		racks = NewHash[string]()
+		t.racks[zone] = racks
+		t.zones.Add(zone)
+	}
+	nodes, ok := t.nodes[id]
+	if !ok {
+		nodes = NewHash[T]()
+		t.nodes[id] = nodes
+		racks.Add(rack)
+	}
+	nodes.Add(node)
+	t.where[node] = id
+	return nil
+}
+
+// Remove removes a node from the topology. The rack and the zone of the node
+// are removed as well if they become empty.
+func (t *Topology[T]) Remove(node T) {
+	id, ok := t.where[node]
+	if !ok {
+		return
+	}
+
+	delete(t.where, node)
+	nodes := t.nodes[id]
+	nodes.Remove(node)
+	if nodes.Len() > 0 {
+		return
+	}
+	delete(t.nodes, id)
+	racks := t.racks[id.zone]
+	racks.Remove(id.rack)
+	if racks.Len() > 0 {
+		return
+	}
+	delete(t.racks, id.zone)
+	t.zones.Remove(id.zone)
+}
+
+// Location returns the zone and the rack of a node, and reports whether the
+// node is in the topology.
+func (t *Topology[T]) Location(node T) (zone, rack string, ok bool) {
+	id, ok := t.where[node]
+	return id.zone, id.rack, ok
+}
+
+// Len returns the number of nodes in the topology.
+func (t *Topology[T]) Len() int {
+	return len(t.where)
+}
+
+// Zones returns all the zones in the topology.
+func (t *Topology[T]) Zones() []string {
+	return t.zones.All()
+}
+
+// Get returns the node for the key and reports whether it succeeded.
+func (t *Topology[T]) Get(key uint64) (node T, ok bool) {
+	zone, ok := t.zones.Get(key)
+	if !ok {
+		return node, false
+	}
+	rack, _ := t.racks[zone].Get(mix64(key ^ rackSalt))
+	return t.nodes[rackID{zone: zone, rack: rack}].Get(mix64(key ^ nodeSalt))
+}
+
+// GetN returns up to n distinct nodes for the key, ordered by preference. The
+// first node is always the one returned by Get. The nodes are spread over as
+// many zones as possible, and within a zone over as many racks as possible:
+// no zone appears twice before every zone has appeared once, and likewise for
+// the racks of a zone.
+func (t *Topology[T]) GetN(key uint64, n int) []T {
+	if n > len(t.where) {
+		n = len(t.where)
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	zones := t.zones.GetN(key, n)
+	lists := make([][]T, len(zones))
+	for i, zone := range zones {
+		lists[i] = t.getInZone(zone, key, n)
+	}
+	return interleave(make([]T, 0, n), lists, n)
+}
+
+func (t *Topology[T]) getInZone(zone string, key uint64, n int) []T {
+	racks := t.racks[zone].GetN(mix64(key^rackSalt), n)
+	lists := make([][]T, len(racks))
+	var total int
+	for i, rack := range racks {
+		lists[i] = t.nodes[rackID{zone: zone, rack: rack}].GetN(mix64(key^nodeSalt), n)
+		total += len(lists[i])
+	}
+	if total < n {
+		n = total
+	}
+	return interleave(make([]T, 0, n), lists, n)
+}
+
+// interleave appends up to n elements to dst by taking the elements of lists
+// in rounds: the i-th round takes the i-th element of every list.
+func interleave[T comparable](dst []T, lists [][]T, n int) []T {
+	for i := 0; len(dst) < n; i++ {
+		var taken bool
+		for _, a := range lists {
+			if i < len(a) && len(dst) < n {
+				dst = append(dst, a[i])
+				taken = true
+			}
+		}
+		if !taken {
+			break
+		}
+	}
+	return dst
+}
@@ -0,0 +1,147 @@
+package doublejump
+
+import (
+	"fmt"
+	"testing"
+)
+
+func newTestTopology(zones, racks, nodes int) *Topology[string] {
+	t := NewTopology[string]()
+	for z := 0; z < zones; z++ {
+		for r := 0; r < racks; r++ {
+			for n := 0; n < nodes; n++ {
+				zone, rack := fmt.Sprintf("z%d", z), fmt.Sprintf("r%d", r)
+				_ = t.Add(zone, rack, fmt.Sprintf("%s-%s-n%d", zone, rack, n))
+			}
+		}
+	}
+	return t
+}
+
+func TestTopology_Get(t *testing.T) {
+	topo := NewTopology[string]()
+	if _, ok := topo.Get(1); ok {
+		t.Fatal("ok should be false when topo is empty")
+	}
+	if a := topo.GetN(1, 3); len(a) != 0 {
+		t.Fatal("GetN should return nothing when topo is empty")
+	}
+
+	topo = newTestTopology(3, 4, 5)
+	if topo.Len() != 60 || len(topo.Zones()) != 3 {
+		t.Fatal("something is wrong with Add")
+	}
+	if err := topo.Add("z9", "r9", "z0-r0-n0"); err != ErrObjectExists {
+		t.Fatal("Add should fail when the node exists")
+	}
+	if zone, rack, ok := topo.Location("z1-r2-n3"); !ok || zone != "z1" || rack != "r2" {
+		t.Fatal("something is wrong with Location")
+	}
+
+	counts := make(map[string]int)
+	for key := uint64(0); key < 60000; key++ {
+		node, ok := topo.Get(key)
+		if !ok {
+			t.Fatal("Get failed")
+		}
+		counts[node]++
+	}
+	if len(counts) != 60 {
+		t.Fatalf("some nodes receive no key. len(counts): %d", len(counts))
+	}
+	for node, c := range counts {
+		if c < 700 || c > 1300 {
+			t.Fatalf("the keys are unbalanced. node: %s, c: %d", node, c)
+		}
+	}
+}
+
+func TestTopology_Remove(t *testing.T) {
+	topo := newTestTopology(3, 4, 5)
+	owners := make([]string, 10000)
+	for key := range owners {
+		owners[key], _ = topo.Get(uint64(key))
+	}
+
+	topo.Remove("z1-r2-n3")
+	topo.Remove("z1-r2-n3")
+	if topo.Len() != 59 {
+		t.Fatal("something is wrong with Remove")
+	}
+	for key, owner := range owners {
+		node, _ := topo.Get(uint64(key))
+		if node == "z1-r2-n3" {
+			t.Fatal("the removed node should receive no key")
+		}
+		if owner == "z1-r2-n3" {
+			if zone, rack, _ := topo.Location(node); zone != "z1" || rack != "r2" {
+				t.Fatalf("the keys should stay in the rack. node: %s", node)
+			}
+		} else if node != owner {
+			t.Fatalf("only the keys of the removed node should move. owner: %s, node: %s", owner, node)
+		}
+	}
+
+	for n := 0; n < 5; n++ {
+		topo.Remove(fmt.Sprintf("z2-r0-n%d", n))
+	}
+	for r := 0; r < 4; r++ {
+		for n := 0; n < 5; n++ {
+			topo.Remove(fmt.Sprintf("z0-r%d-n%d", r, n))
+		}
+	}
+	if topo.Len() != 34 || len(topo.Zones()) != 2 || len(topo.racks["z2"].All()) != 3 {
+		t.Fatal("the empty racks and zones should be removed")
+	}
+	for key := uint64(0); key < 10000; key++ {
+		node, ok := topo.Get(key)
+		if zone, _, _ := topo.Location(node); !ok || zone == "z0" {
+			t.Fatalf("Get should return an existing node. node: %s", node)
+		}
+	}
+}
+
+func TestTopology_GetN(t *testing.T) {
+	topo := newTestTopology(3, 4, 2)
+	for key := uint64(0); key < 10000; key++ {
+		a := topo.GetN(key, 9)
+		if len(a) != 9 {
+			t.Fatalf("len(a) != 9. len(a): %d", len(a))
+		}
+		if node, _ := topo.Get(key); a[0] != node {
+			t.Fatalf("a[0] != topo.Get(key). a[0]: %s, node: %s", a[0], node)
+		}
+		seen := make(map[string]struct{})
+		zones := make(map[string]int)
+		racks := make(map[rackID]int)
+		for _, node := range a {
+			seen[node] = struct{}{}
+			zone, rack, _ := topo.Location(node)
+			zones[zone]++
+			racks[rackID{zone: zone, rack: rack}]++
+			if len(seen) <= 3 && len(zones) != len(seen) {
+				t.Fatalf("the first nodes should be in different zones. a: %v", a)
+			}
+		}
+		if len(seen) != 9 {
+			t.Fatalf("the nodes returned by GetN should be distinct. a: %v", a)
+		}
+		for zone, c := range zones {
+			if c != 3 {
+				t.Fatalf("the nodes should be spread over the zones. zone: %s, a: %v", zone, a)
+			}
+		}
+		for id, c := range racks {
+			if c != 1 {
+				t.Fatalf("the nodes should be spread over the racks. rack: %v, a: %v", id, a)
+			}
+		}
+	}
+
+	if a := topo.GetN(1, 100); len(a) != 24 {
+		t.Fatalf("GetN should return all nodes when n > topo.Len(). len(a): %d", len(a))
+	}
+	if a := topo.GetN(1, 0); len(a) != 0 {
+		t.Fatal("GetN should return nothing when n is 0")
+	}
+}